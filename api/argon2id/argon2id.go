@@ -1,6 +1,7 @@
 package argon2id
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/subtle"
 	"encoding/base64"
@@ -8,8 +9,11 @@ import (
 	"fmt"
 	"runtime"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/crypto/argon2"
+	"golang.org/x/sync/semaphore"
 )
 
 const Version = argon2.Version
@@ -18,6 +22,9 @@ var (
 	ErrInvalidFormat       = errors.New("argon2id: invalid format")
 	ErrIncompatibleVariant = errors.New("argon2id: incompatible variant")
 	ErrIncompatibleVersion = errors.New("argon2id: incompatible version")
+	ErrInvalidParams       = errors.New("argon2id: invalid params")
+	ErrInsufficientBudget  = errors.New("argon2id: memory budget is lower than the memory parameter")
+	ErrQueueTimeout        = errors.New("argon2id: timed out waiting for memory budget")
 )
 
 var DefaultParams = &Params{
@@ -29,11 +36,18 @@ var DefaultParams = &Params{
 }
 
 type Params struct {
-	Memory      uint32
-	Iterations  uint32
-	Parallelism uint8
-	SaltLength  uint32
-	KeyLength   uint32
+	Memory      uint32 `yaml:"memory"`
+	Iterations  uint32 `yaml:"iterations"`
+	Parallelism uint8  `yaml:"parallelism"`
+	SaltLength  uint32 `yaml:"saltLength"`
+	KeyLength   uint32 `yaml:"keyLength"`
+}
+
+func (p *Params) Validate() error {
+	if p.Parallelism < 1 || p.Memory < 8*uint32(p.Parallelism) || p.Iterations < 1 || p.SaltLength < 8 || p.KeyLength < 4 {
+		return ErrInvalidParams
+	}
+	return nil
 }
 
 func Key(params *Params, salt, password []byte) []byte {
@@ -107,32 +121,113 @@ func RandomSalt(n uint32) ([]byte, error) {
 	return b, nil
 }
 
+type Config struct {
+	Params       Params        `yaml:"params"`
+	MemoryBudget uint32        `yaml:"memoryBudget"`
+	QueueTimeout time.Duration `yaml:"queueTimeout"`
+}
+
+type Stats struct {
+	Queued      int64   `json:"queued"`
+	MemoryInUse int64   `json:"memoryInUse"`
+	Acquired    uint64  `json:"acquired"`
+	Timeouts    uint64  `json:"timeouts"`
+	WaitSeconds float64 `json:"waitSeconds"`
+}
+
 type Service interface {
-	Hash([]byte) (string, error)
-	Compare([]byte, string) (bool, bool, error)
+	Hash(context.Context, []byte) (string, error)
+	Compare(context.Context, []byte, string) (bool, bool, error)
+	Stats() Stats
 }
 
-func NewService(params *Params) Service {
-	return &service{params}
+func NewService(cfg *Config) (Service, error) {
+	if err := cfg.Params.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.MemoryBudget < cfg.Params.Memory {
+		return nil, ErrInsufficientBudget
+	}
+	return &service{
+		params:       &cfg.Params,
+		budget:       cfg.MemoryBudget,
+		queueTimeout: cfg.QueueTimeout,
+		semaphore:    semaphore.NewWeighted(int64(cfg.MemoryBudget)),
+	}, nil
 }
 
 type service struct {
-	params *Params
+	params       *Params
+	budget       uint32
+	queueTimeout time.Duration
+	semaphore    *semaphore.Weighted
+	queued       atomic.Int64
+	inUse        atomic.Int64
+	acquired     atomic.Uint64
+	timeouts     atomic.Uint64
+	waitTime     atomic.Int64
 }
 
-func (s *service) Hash(password []byte) (hash string, err error) {
+func (s *service) acquire(ctx context.Context, memory uint32) (func(), error) {
+	n := int64(min(memory, s.budget))
+	start := time.Now()
+	if s.queueTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, s.queueTimeout, ErrQueueTimeout)
+		defer cancel()
+	}
+	s.queued.Add(1)
+	err := s.semaphore.Acquire(ctx, n)
+	s.queued.Add(-1)
+	s.waitTime.Add(int64(time.Since(start)))
+	if err != nil {
+		if errors.Is(context.Cause(ctx), ErrQueueTimeout) {
+			s.timeouts.Add(1)
+			return nil, ErrQueueTimeout
+		}
+		return nil, err
+	}
+	s.acquired.Add(1)
+	s.inUse.Add(n)
+	return func() {
+		s.inUse.Add(-n)
+		s.semaphore.Release(n)
+	}, nil
+}
+
+func (s *service) Stats() Stats {
+	return Stats{
+		Queued:      s.queued.Load(),
+		MemoryInUse: s.inUse.Load(),
+		Acquired:    s.acquired.Load(),
+		Timeouts:    s.timeouts.Load(),
+		WaitSeconds: time.Duration(s.waitTime.Load()).Seconds(),
+	}
+}
+
+func (s *service) Hash(ctx context.Context, password []byte) (hash string, err error) {
 	salt, err := RandomSalt(s.params.SaltLength)
 	if err != nil {
 		return "", err
 	}
+	release, err := s.acquire(ctx, s.params.Memory)
+	if err != nil {
+		return "", err
+	}
+	defer release()
 	return Encode(s.params, salt, password), nil
 }
 
-func (s *service) Compare(password []byte, hash string) (match bool, rotate bool, err error) {
+func (s *service) Compare(ctx context.Context, password []byte, hash string) (match bool, rotate bool, err error) {
 	params, salt, key, err := Decode(hash)
 	if err != nil {
 		return false, false, err
 	}
+	release, err := s.acquire(ctx, params.Memory)
+	if err != nil {
+		return false, false, err
+	}
+	defer release()
 	otherKey := Key(params, salt, password)
 	if subtle.ConstantTimeEq(int32(len(key)), int32(len(otherKey))) == 0 {
 		return false, false, nil
@@ -1,8 +1,10 @@
 package argon2id
 
 import (
+	"context"
 	"regexp"
 	"testing"
+	"time"
 )
 
 var (
@@ -19,7 +21,8 @@ var (
 		SaltLength:  16,
 		KeyLength:   32,
 	}
-	s1, s2 = NewService(p1), NewService(p2)
+	s1, _  = NewService(&Config{Params: *p1, MemoryBudget: 128 * 1024, QueueTimeout: time.Second})
+	s2, _  = NewService(&Config{Params: *p2, MemoryBudget: 128 * 1024, QueueTimeout: time.Second})
 	k1, k2 = []byte("pa$$word123"), []byte("ot#er123")
 )
 
@@ -39,7 +42,7 @@ func TestEncode(t *testing.T) {
 }
 
 func TestDecode(t *testing.T) {
-	hash, err := s1.Hash(k1)
+	hash, err := s1.Hash(context.Background(), k1)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -69,11 +72,11 @@ func TestDecode(t *testing.T) {
 }
 
 func TestHash(t *testing.T) {
-	h1, err := s1.Hash(k1)
+	h1, err := s1.Hash(context.Background(), k1)
 	if err != nil {
 		t.Fatal(err)
 	}
-	h2, err := s1.Hash(k1)
+	h2, err := s1.Hash(context.Background(), k1)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -83,11 +86,11 @@ func TestHash(t *testing.T) {
 }
 
 func TestCompare(t *testing.T) {
-	h1, err := s1.Hash(k1)
+	h1, err := s1.Hash(context.Background(), k1)
 	if err != nil {
 		t.Fatal(err)
 	}
-	h2, err := s2.Hash(k2)
+	h2, err := s2.Hash(context.Background(), k2)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -105,7 +108,7 @@ func TestCompare(t *testing.T) {
 		{k2, "$argon2id$v=19$m=65536,t=2,p=1$V2xpOG5FTlRHWDZUV09VVA$wYb/TTEdEJ34ADS0S0iiWRb7Oqt81lctiUxtkOjKkkg", true, true},
 	}
 	for _, test := range tests {
-		match, rotate, err := s1.Compare(test.password, test.hash)
+		match, rotate, err := s1.Compare(context.Background(), test.password, test.hash)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -117,3 +120,55 @@ func TestCompare(t *testing.T) {
 		}
 	}
 }
+
+func TestNewService(t *testing.T) {
+	tests := []struct {
+		cfg *Config
+		err error
+	}{
+		{&Config{Params: *p1, MemoryBudget: p1.Memory}, nil},
+		{&Config{Params: *p1, MemoryBudget: p1.Memory - 1}, ErrInsufficientBudget},
+		{&Config{Params: Params{Memory: 64, Iterations: 1, Parallelism: 16, SaltLength: 16, KeyLength: 32}, MemoryBudget: 64}, ErrInvalidParams},
+		{&Config{Params: Params{Memory: 1024, Iterations: 0, Parallelism: 1, SaltLength: 16, KeyLength: 32}, MemoryBudget: 1024}, ErrInvalidParams},
+		{&Config{Params: Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 4, KeyLength: 32}, MemoryBudget: 1024}, ErrInvalidParams},
+		{&Config{}, ErrInvalidParams},
+	}
+	for _, test := range tests {
+		if _, err := NewService(test.cfg); err != test.err {
+			t.Fatalf("expected error: %v, got: %v", test.err, err)
+		}
+	}
+}
+
+func TestQueueTimeout(t *testing.T) {
+	s, err := NewService(&Config{Params: *p1, MemoryBudget: p1.Memory, QueueTimeout: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	release, err := s.(*service).acquire(context.Background(), p1.Memory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Hash(context.Background(), k1); err != ErrQueueTimeout {
+		t.Fatalf("expected error: %v, got: %v", ErrQueueTimeout, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err = s.Compare(ctx, k1, "$argon2id$v=19$m=65536,t=1,p=1$UXdlWUphcVNwOFBJSVJodQ$DpuM7N26KOVtgUUL5GUsyMnHEUjdDnzce7i/I93xgRI"); err != context.Canceled {
+		t.Fatalf("expected error: %v, got: %v", context.Canceled, err)
+	}
+	stats := s.Stats()
+	if stats.Queued != 0 || stats.MemoryInUse != int64(p1.Memory) || stats.Acquired != 1 || stats.Timeouts != 1 {
+		t.Fatalf("expected one acquired and one timeout, got: %+v", stats)
+	}
+	if stats.WaitSeconds <= 0 {
+		t.Fatalf("expected positive wait time, got: %v", stats.WaitSeconds)
+	}
+	release()
+	if _, err = s.Hash(context.Background(), k1); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if stats = s.Stats(); stats.MemoryInUse != 0 || stats.Acquired != 2 {
+		t.Fatalf("expected released memory, got: %+v", stats)
+	}
+}
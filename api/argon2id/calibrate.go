@@ -0,0 +1,38 @@
+package argon2id
+
+import "time"
+
+func Benchmark(params *Params, samples int) time.Duration {
+	salt := make([]byte, params.SaltLength)
+	best := time.Duration(0)
+	for i := 0; i < max(samples, 1); i++ {
+		start := time.Now()
+		Key(params, salt, salt)
+		if d := time.Since(start); i == 0 || d < best {
+			best = d
+		}
+	}
+	return best
+}
+
+func Calibrate(target time.Duration, maxMemory uint32, parallelism uint8, samples int) (Params, time.Duration) {
+	params := *DefaultParams
+	params.Memory = maxMemory
+	params.Iterations = 1
+	params.Parallelism = parallelism
+	d := Benchmark(&params, samples)
+	for d > target && params.Memory/2 >= 8*uint32(parallelism) {
+		params.Memory /= 2
+		d = Benchmark(&params, samples)
+	}
+	if d >= target {
+		return params, d
+	}
+	params.Iterations = 2
+	perIteration := max(Benchmark(&params, samples)-d, time.Microsecond)
+	params.Iterations = uint32(max((target-d)/perIteration+1, 1))
+	for d = Benchmark(&params, samples); d > target && params.Iterations > 1; d = Benchmark(&params, samples) {
+		params.Iterations--
+	}
+	return params, d
+}
@@ -0,0 +1,33 @@
+package argon2id
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalibrate(t *testing.T) {
+	tests := []struct {
+		target      time.Duration
+		maxMemory   uint32
+		parallelism uint8
+	}{
+		{20 * time.Millisecond, 1024, 1},
+		{20 * time.Millisecond, 8 * 1024, 2},
+		{time.Nanosecond, 1024, 1},
+	}
+	for _, test := range tests {
+		params, _ := Calibrate(test.target, test.maxMemory, test.parallelism, 1)
+		if err := params.Validate(); err != nil {
+			t.Fatalf("expected valid params, got: %+v", params)
+		}
+		if params.Memory > test.maxMemory {
+			t.Fatalf("expected memory at most: %d, got: %d", test.maxMemory, params.Memory)
+		}
+		if params.Parallelism != test.parallelism {
+			t.Fatalf("expected parallelism: %d, got: %d", test.parallelism, params.Parallelism)
+		}
+	}
+	if params, _ := Calibrate(time.Nanosecond, 1024, 1, 1); params.Memory != 8 || params.Iterations != 1 {
+		t.Fatalf("expected minimum params, got: %+v", params)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/cyberwlodarczyk/auth/api/argon2id"
+	"github.com/goccy/go-yaml"
+)
+
+func calibrate(args []string) error {
+	flags := flag.NewFlagSet("calibrate", flag.ContinueOnError)
+	latency := flags.Duration("latency", 500*time.Millisecond, "target hashing latency")
+	memory := flags.Uint64("memory", uint64(argon2id.DefaultParams.Memory), "memory ceiling in KiB")
+	parallelism := flags.Uint("parallelism", uint(min(runtime.NumCPU(), 255)), "degree of parallelism")
+	samples := flags.Int("samples", 3, "benchmark runs per measurement")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *latency <= 0 || *parallelism < 1 || *parallelism > 255 || *memory < 8*uint64(*parallelism) || *memory > 1<<32-1 {
+		return fmt.Errorf("calibrate: %w", argon2id.ErrInvalidParams)
+	}
+	params, measured := argon2id.Calibrate(*latency, uint32(*memory), uint8(*parallelism), *samples)
+	out, err := yaml.Marshal(map[string]any{
+		"argon2id": map[string]any{"params": params},
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "# measured latency: %v\n%s", measured.Round(time.Millisecond), out)
+	return nil
+}
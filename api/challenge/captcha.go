@@ -0,0 +1,90 @@
+package challenge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var ErrMissingEndpoint = errors.New("challenge: captcha endpoint must be an absolute url")
+
+type CaptchaConfig struct {
+	Endpoint string        `yaml:"endpoint"`
+	SiteKey  string        `yaml:"siteKey"`
+	Secret   string        `env:"SECRET" envDefault:""`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+type CaptchaError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *CaptchaError) Error() string {
+	return fmt.Sprintf("challenge: captcha provider responded with %d: %s", e.StatusCode, e.Body)
+}
+
+func NewCaptchaVerifier(cfg *CaptchaConfig) (Verifier, error) {
+	if u, err := url.Parse(cfg.Endpoint); err != nil || !u.IsAbs() || u.Host == "" {
+		return nil, ErrMissingEndpoint
+	}
+	return &captchaVerifier{
+		endpoint: cfg.Endpoint,
+		siteKey:  cfg.SiteKey,
+		secret:   cfg.Secret,
+		client:   &http.Client{Timeout: cfg.Timeout},
+	}, nil
+}
+
+type captchaVerifier struct {
+	endpoint string
+	siteKey  string
+	secret   string
+	client   *http.Client
+}
+
+func (v *captchaVerifier) Issue(ctx context.Context) (Challenge, error) {
+	return Challenge{Type: ProviderCaptcha, SiteKey: v.siteKey}, nil
+}
+
+func (v *captchaVerifier) Close() {}
+
+func (v *captchaVerifier) Verify(ctx context.Context, response, ip string) error {
+	if response == "" {
+		return ErrInvalidResponse
+	}
+	form := url.Values{"secret": {v.secret}, "response": {response}}
+	if ip != "" {
+		form.Set("remoteip", ip)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return &CaptchaError{res.StatusCode, string(body)}
+	}
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err = json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&result); err != nil {
+		return err
+	}
+	if !result.Success {
+		return ErrInvalidResponse
+	}
+	return nil
+}
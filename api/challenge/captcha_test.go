@@ -0,0 +1,74 @@
+package challenge
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCaptchaVerifier(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("secret") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.PostFormValue("response") == "passed" && r.PostFormValue("remoteip") == "192.0.2.1" {
+			w.Write([]byte(`{"success": true}`))
+			return
+		}
+		w.Write([]byte(`{"success": false, "error-codes": ["invalid-input-response"]}`))
+	}))
+	defer server.Close()
+	if _, err := NewCaptchaVerifier(&CaptchaConfig{Endpoint: "/verify"}); err != ErrMissingEndpoint {
+		t.Fatalf("expected error: %v, got: %v", ErrMissingEndpoint, err)
+	}
+	v, err := NewVerifier(&Config{
+		Provider: ProviderCaptcha,
+		Captcha: CaptchaConfig{
+			Endpoint: server.URL,
+			SiteKey:  "site",
+			Secret:   "secret",
+			Timeout:  time.Second,
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	ctx := context.Background()
+	c, err := v.Issue(ctx)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if c.Type != ProviderCaptcha || c.SiteKey != "site" {
+		t.Fatalf("expected captcha challenge, got: %+v", c)
+	}
+	tests := []struct {
+		response string
+		ip       string
+		expected error
+	}{
+		{"passed", "192.0.2.1", nil},
+		{"passed", "192.0.2.2", ErrInvalidResponse},
+		{"failed", "192.0.2.1", ErrInvalidResponse},
+		{"", "192.0.2.1", ErrInvalidResponse},
+	}
+	for _, test := range tests {
+		if err = v.Verify(ctx, test.response, test.ip); err != test.expected {
+			t.Fatalf("expected error: %v, got: %v", test.expected, err)
+		}
+	}
+	v, err = NewCaptchaVerifier(&CaptchaConfig{Endpoint: server.URL, Secret: "wrong"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var captchaErr *CaptchaError
+	if err = v.Verify(ctx, "passed", "192.0.2.1"); !errors.As(err, &captchaErr) || captchaErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected error: %v, got: %v", &CaptchaError{StatusCode: http.StatusForbidden}, err)
+	}
+	if _, err = NewVerifier(&Config{Provider: "unknown"}); !errors.Is(err, ErrUnknownProvider) {
+		t.Fatalf("expected error: %v, got: %v", ErrUnknownProvider, err)
+	}
+}
@@ -0,0 +1,47 @@
+package challenge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+const (
+	ProviderPoW     = "pow"
+	ProviderCaptcha = "captcha"
+)
+
+var (
+	ErrUnknownProvider = errors.New("challenge: unknown provider")
+	ErrInvalidResponse = errors.New("challenge: response is invalid")
+)
+
+type Config struct {
+	Provider string        `yaml:"provider"`
+	PoW      PoWConfig     `yaml:"pow" envPrefix:"POW_"`
+	Captcha  CaptchaConfig `yaml:"captcha" envPrefix:"CAPTCHA_"`
+}
+
+type Challenge struct {
+	Type       string `json:"type"`
+	Token      string `json:"token,omitempty"`
+	Difficulty int    `json:"difficulty,omitempty"`
+	SiteKey    string `json:"siteKey,omitempty"`
+}
+
+type Verifier interface {
+	Issue(context.Context) (Challenge, error)
+	Verify(ctx context.Context, response, ip string) error
+	Close()
+}
+
+func NewVerifier(cfg *Config) (Verifier, error) {
+	switch cfg.Provider {
+	case ProviderPoW:
+		return NewPoWVerifier(&cfg.PoW)
+	case ProviderCaptcha:
+		return NewCaptchaVerifier(&cfg.Captcha)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, cfg.Provider)
+	}
+}
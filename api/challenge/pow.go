@@ -0,0 +1,159 @@
+package challenge
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/bits"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cyberwlodarczyk/auth/api/jwt"
+	"github.com/cyberwlodarczyk/auth/api/ratelimit"
+)
+
+const maxDifficulty = 32
+
+var (
+	ErrInvalidDifficulty = errors.New("challenge: difficulty must be between 1 and 32")
+	ErrMissingSecret     = errors.New("challenge: pow token secret is required")
+)
+
+type PoWTokenConfig struct {
+	Secret jwt.Secret    `env:"SECRET" envDefault:""`
+	Age    time.Duration `yaml:"age"`
+}
+
+type PoWConfig struct {
+	Difficulty int            `yaml:"difficulty"`
+	Token      PoWTokenConfig `yaml:"token" envPrefix:"TOKEN_"`
+	RateLimit  ratelimit.Service
+}
+
+type powToken struct {
+	Nonce      string `json:"nonce"`
+	Difficulty int    `json:"difficulty"`
+}
+
+func NewPoWVerifier(cfg *PoWConfig) (Verifier, error) {
+	if cfg.Difficulty < 1 || cfg.Difficulty > maxDifficulty {
+		return nil, ErrInvalidDifficulty
+	}
+	if len(cfg.Token.Secret) == 0 {
+		return nil, ErrMissingSecret
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	v := &powVerifier{
+		difficulty: cfg.Difficulty,
+		token:      jwt.NewService[powToken](jwt.Config{Secret: cfg.Token.Secret, Age: cfg.Token.Age}),
+		used:       make(map[string]time.Time),
+		cancel:     cancel,
+	}
+	if cfg.RateLimit != nil {
+		v.nonces = cfg.RateLimit.NewLimiter("challenge.pow", ratelimit.Params{
+			Rate:  1 / (cfg.Token.Age + jwt.Leeway).Seconds(),
+			Burst: 1,
+		})
+		return v, nil
+	}
+	v.wg.Add(1)
+	go v.prune(ctx, max(cfg.Token.Age, time.Second))
+	return v, nil
+}
+
+type powVerifier struct {
+	difficulty int
+	token      jwt.Service[powToken]
+	used       map[string]time.Time
+	nonces     ratelimit.Limiter
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+	mutex      sync.Mutex
+}
+
+func (v *powVerifier) Issue(ctx context.Context) (Challenge, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return Challenge{}, err
+	}
+	token, err := v.token.Sign(powToken{hex.EncodeToString(nonce), v.difficulty})
+	if err != nil {
+		return Challenge{}, err
+	}
+	return Challenge{Type: ProviderPoW, Token: token, Difficulty: v.difficulty}, nil
+}
+
+func leadingZeroBits(sum []byte) int {
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+func (v *powVerifier) Verify(ctx context.Context, response, ip string) error {
+	token, counter, ok := strings.Cut(response, ":")
+	if !ok || counter == "" {
+		return ErrInvalidResponse
+	}
+	data, err := v.token.Verify(token)
+	if err != nil {
+		return ErrInvalidResponse
+	}
+	sum := sha256.Sum256([]byte(response))
+	if leadingZeroBits(sum[:]) < data.Difficulty {
+		return ErrInvalidResponse
+	}
+	if v.nonces != nil {
+		result, err := v.nonces.Allow(ctx, data.Nonce)
+		if err != nil {
+			return err
+		}
+		if !result.Allowed {
+			return ErrInvalidResponse
+		}
+		return nil
+	}
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if _, ok = v.used[data.Nonce]; ok {
+		return ErrInvalidResponse
+	}
+	v.used[data.Nonce] = time.Now().Add(v.token.Age() + jwt.Leeway)
+	return nil
+}
+
+func (v *powVerifier) evict(now time.Time) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	for nonce, expiresAt := range v.used {
+		if now.After(expiresAt) {
+			delete(v.used, nonce)
+		}
+	}
+}
+
+func (v *powVerifier) prune(ctx context.Context, interval time.Duration) {
+	defer v.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			v.evict(now)
+		}
+	}
+}
+
+func (v *powVerifier) Close() {
+	v.cancel()
+	v.wg.Wait()
+}
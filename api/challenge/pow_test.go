@@ -0,0 +1,153 @@
+package challenge
+
+import (
+	"context"
+	"crypto/sha256"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/cyberwlodarczyk/auth/api/ratelimit"
+)
+
+func solve(token string, difficulty int) string {
+	for counter := uint64(0); ; counter++ {
+		response := token + ":" + strconv.FormatUint(counter, 16)
+		sum := sha256.Sum256([]byte(response))
+		if leadingZeroBits(sum[:]) >= difficulty {
+			return response
+		}
+	}
+}
+
+func TestLeadingZeroBits(t *testing.T) {
+	tests := []struct {
+		given    []byte
+		expected int
+	}{
+		{[]byte{0x80}, 0},
+		{[]byte{0x01}, 7},
+		{[]byte{0x00, 0x40}, 9},
+		{[]byte{0x00, 0x00}, 16},
+	}
+	for _, test := range tests {
+		if n := leadingZeroBits(test.given); n != test.expected {
+			t.Fatalf("expected bits: %d, got: %d", test.expected, n)
+		}
+	}
+}
+
+func TestPoWVerifier(t *testing.T) {
+	if _, err := NewPoWVerifier(&PoWConfig{Difficulty: 0}); err != ErrInvalidDifficulty {
+		t.Fatalf("expected error: %v, got: %v", ErrInvalidDifficulty, err)
+	}
+	if _, err := NewPoWVerifier(&PoWConfig{Difficulty: 8, Token: PoWTokenConfig{Age: time.Minute}}); err != ErrMissingSecret {
+		t.Fatalf("expected error: %v, got: %v", ErrMissingSecret, err)
+	}
+	cfg := &PoWConfig{
+		Difficulty: 8,
+		Token:      PoWTokenConfig{Secret: []byte("secret"), Age: time.Minute},
+	}
+	v, err := NewPoWVerifier(cfg)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer v.Close()
+	ctx := context.Background()
+	c, err := v.Issue(ctx)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if c.Type != ProviderPoW || c.Difficulty != 8 || c.Token == "" {
+		t.Fatalf("expected pow challenge, got: %+v", c)
+	}
+	response := solve(c.Token, c.Difficulty)
+	other, err := NewPoWVerifier(&PoWConfig{
+		Difficulty: 8,
+		Token:      PoWTokenConfig{Secret: []byte("other"), Age: time.Minute},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer other.Close()
+	weak := c.Token + ":0"
+	for {
+		sum := sha256.Sum256([]byte(weak))
+		if leadingZeroBits(sum[:]) < 8 {
+			break
+		}
+		weak += "0"
+	}
+	tests := []struct {
+		verifier Verifier
+		response string
+		expected error
+	}{
+		{v, "", ErrInvalidResponse},
+		{v, c.Token, ErrInvalidResponse},
+		{v, weak, ErrInvalidResponse},
+		{other, response, ErrInvalidResponse},
+		{v, response, nil},
+		{v, response, ErrInvalidResponse},
+	}
+	for _, test := range tests {
+		if err = test.verifier.Verify(ctx, test.response, "192.0.2.1"); err != test.expected {
+			t.Fatalf("expected error: %v, got: %v", test.expected, err)
+		}
+	}
+	pow := v.(*powVerifier)
+	pow.evict(time.Now())
+	if len(pow.used) != 1 {
+		t.Fatalf("expected used nonces: 1, got: %d", len(pow.used))
+	}
+	pow.evict(time.Now().Add(time.Hour))
+	if len(pow.used) != 0 {
+		t.Fatalf("expected used nonces: 0, got: %d", len(pow.used))
+	}
+}
+
+func TestPoWVerifierRateLimit(t *testing.T) {
+	m := miniredis.RunT(t)
+	ctx := context.Background()
+	var verifiers []Verifier
+	for range 2 {
+		rl, err := ratelimit.NewRedisService(ctx, &ratelimit.RedisConfig{Addr: m.Addr(), Prefix: "test:"})
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		defer rl.Close()
+		v, err := NewPoWVerifier(&PoWConfig{
+			Difficulty: 8,
+			Token:      PoWTokenConfig{Secret: []byte("secret"), Age: time.Minute},
+			RateLimit:  rl,
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		defer v.Close()
+		verifiers = append(verifiers, v)
+	}
+	v, other := verifiers[0], verifiers[1]
+	c, err := v.Issue(ctx)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	response := solve(c.Token, c.Difficulty)
+	tests := []struct {
+		verifier Verifier
+		expected error
+	}{
+		{v, nil},
+		{other, ErrInvalidResponse},
+		{v, ErrInvalidResponse},
+	}
+	for _, test := range tests {
+		if err = test.verifier.Verify(ctx, response, "192.0.2.1"); err != test.expected {
+			t.Fatalf("expected error: %v, got: %v", test.expected, err)
+		}
+	}
+	if used := len(v.(*powVerifier).used); used != 0 {
+		t.Fatalf("expected used nonces: 0, got: %d", used)
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"time"
 
 	"github.com/caarlos0/env/v11"
+	"github.com/cyberwlodarczyk/auth/api/argon2id"
+	"github.com/cyberwlodarczyk/auth/api/challenge"
+	"github.com/cyberwlodarczyk/auth/api/firewall"
 	"github.com/cyberwlodarczyk/auth/api/handler"
 	"github.com/cyberwlodarczyk/auth/api/jwt"
 	"github.com/cyberwlodarczyk/auth/api/postgres"
@@ -16,24 +19,30 @@ import (
 
 type Config struct {
 	HTTP struct {
-		Host         string        `env:"HOST"`
-		Port         string        `env:"PORT"`
-		TLSCert      string        `env:"TLS_CERT"`
-		TLSKey       string        `env:"TLS_KEY"`
-		BodyLimit    int           `yaml:"bodyLimit"`
-		HeaderLimit  int           `yaml:"headerLimit"`
-		ReadTimeout  time.Duration `yaml:"readTimeout"`
-		WriteTimeout time.Duration `yaml:"writeTimeout"`
-		IdleTimeout  time.Duration `yaml:"idleTimeout"`
+		Host            string        `env:"HOST"`
+		Port            string        `env:"PORT"`
+		TLSCert         string        `env:"TLS_CERT"`
+		TLSKey          string        `env:"TLS_KEY"`
+		MetricsAddr     string        `env:"METRICS_ADDR" envDefault:""`
+		BodyLimit       int           `yaml:"bodyLimit"`
+		HeaderLimit     int           `yaml:"headerLimit"`
+		TrustedProxies  []string      `yaml:"trustedProxies"`
+		ForwardedHeader string        `yaml:"forwardedHeader"`
+		ReadTimeout     time.Duration `yaml:"readTimeout"`
+		WriteTimeout    time.Duration `yaml:"writeTimeout"`
+		IdleTimeout     time.Duration `yaml:"idleTimeout"`
 	} `yaml:"http" envPrefix:"HTTP_"`
 	Routes struct {
-		User struct {
+		Challenge string `yaml:"challenge"`
+		User      struct {
 			Prefix        string `yaml:"_prefix"`
 			Get           string `yaml:"get"`
 			Create        string `yaml:"create"`
 			Delete        string `yaml:"delete"`
 			ResetPassword string `yaml:"resetPassword"`
 			EditName      string `yaml:"editName"`
+			EditLocale    string `yaml:"editLocale"`
+			Notifications string `yaml:"notifications"`
 			EditPassword  string `yaml:"editPassword"`
 			EditEmail     string `yaml:"editEmail"`
 			Token         struct {
@@ -44,42 +53,59 @@ type Config struct {
 				CreateSudo          string `yaml:"createSudo"`
 			} `yaml:"token"`
 		} `yaml:"user"`
+		Mail struct {
+			Prefix  string `yaml:"_prefix"`
+			Webhook string `yaml:"webhook"`
+		} `yaml:"mail"`
+		Admin struct {
+			Prefix      string `yaml:"_prefix"`
+			Bans        string `yaml:"bans"`
+			Ban         string `yaml:"ban"`
+			Mails       string `yaml:"mails"`
+			Suppression string `yaml:"suppression"`
+		} `yaml:"admin"`
 	} `yaml:"routes"`
 	Errors struct {
-		Root handler.Errors     `yaml:"root"`
-		User handler.UserErrors `yaml:"user"`
+		Root  handler.Errors      `yaml:"root"`
+		User  handler.UserErrors  `yaml:"user"`
+		Mail  handler.MailErrors  `yaml:"mail"`
+		Admin handler.AdminErrors `yaml:"admin"`
 	} `yaml:"errors"`
 	Validation struct {
 		User struct {
-			Name     validation.Range          `yaml:"name"`
+			Name     validation.NameConfig     `yaml:"name"`
+			Email    validation.EmailConfig    `yaml:"email"`
+			Domain   validation.DomainConfig   `yaml:"domain"`
 			Password validation.PasswordConfig `yaml:"password"`
 		} `yaml:"user"`
 	} `yaml:"validation"`
+	Argon2id  argon2id.Config  `yaml:"argon2id"`
+	Firewall  firewall.Config  `yaml:"firewall"`
+	Challenge challenge.Config `yaml:"challenge" envPrefix:"CHALLENGE_"`
+	Admin     struct {
+		Secret string `env:"SECRET" envDefault:""`
+	} `envPrefix:"ADMIN_"`
 	RateLimit struct {
-		CleanupInterval time.Duration    `yaml:"cleanupInterval"`
-		IdleTimeout     time.Duration    `yaml:"idleTimeout"`
-		IP              ratelimit.Params `yaml:"ip"`
-		User            struct {
-			Session                  ratelimit.Params `yaml:"session"`
-			Sudo                     ratelimit.Params `yaml:"sudo"`
-			Create                   ratelimit.Params `yaml:"create"`
-			ResetPassword            ratelimit.Params `yaml:"resetPassword"`
-			CreateConfirmationToken  ratelimit.Params `yaml:"createConfirmationToken"`
-			CreatePasswordResetToken ratelimit.Params `yaml:"createPasswordResetToken"`
-			CreateSessionToken       struct {
-				IP    ratelimit.Params `yaml:"ip"`
-				Email ratelimit.Params `yaml:"email"`
-			} `yaml:"createSessionToken"`
-			CreateSudoToken ratelimit.Params `yaml:"createSudoToken"`
-		} `yaml:"user"`
-	} `yaml:"rateLimit"`
+		Backend         string                    `yaml:"backend"`
+		Redis           ratelimit.RedisConfig     `yaml:"redis" envPrefix:"REDIS_"`
+		CleanupInterval time.Duration             `yaml:"cleanupInterval"`
+		IdleTimeout     time.Duration             `yaml:"idleTimeout"`
+		MaxKeys         int                       `yaml:"maxKeys"`
+		Policies        []handler.RateLimitPolicy `yaml:"policies"`
+	} `yaml:"rateLimit" envPrefix:"RATE_LIMIT_"`
 	Mail struct {
+		Templates smtp.TemplatesConfig `yaml:"templates"`
+		Webhook   struct {
+			Secret    string `env:"WEBHOOK_SECRET" envDefault:""`
+			BodyLimit int    `yaml:"bodyLimit"`
+		} `yaml:"webhook"`
 		User struct {
-			Confirmation  handler.UserTokenMail `yaml:"confirmation"`
-			PasswordReset handler.UserTokenMail `yaml:"passwordReset"`
-			Sudo          handler.UserTokenMail `yaml:"sudo"`
+			Confirmation  handler.UserTokenMail     `yaml:"confirmation"`
+			PasswordReset handler.UserTokenMail     `yaml:"passwordReset"`
+			Sudo          handler.UserTokenMail     `yaml:"sudo"`
+			Notifications handler.UserNotifications `yaml:"notifications"`
 		} `yaml:"user"`
-	} `yaml:"mail"`
+	} `yaml:"mail" envPrefix:"MAIL_"`
 	JWT struct {
 		User struct {
 			Confirmation  jwt.Config `yaml:"confirmation" envPrefix:"CONFIRMATION_"`
@@ -0,0 +1,316 @@
+package firewall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	Pass Verdict = iota
+	Exempt
+	Block
+)
+
+const (
+	StrikeTooManyRequests = "tooManyRequests"
+	StrikeFailedLogin     = "failedLogin"
+)
+
+var (
+	ErrInvalidPrefix = errors.New("firewall: prefix must be an ip address or cidr")
+	ErrInvalidBan    = errors.New("firewall: ban duration must be positive")
+	ErrUnknownStrike = errors.New("firewall: unknown strike kind")
+	ErrMissingBanDB  = errors.New("firewall: ban database is required")
+	ErrNotBanned     = errors.New("firewall: ban does not exist")
+)
+
+const refreshTimeout = 10 * time.Second
+
+type Verdict int
+
+type Ban struct {
+	Id        int64        `json:"id"`
+	Prefix    netip.Prefix `json:"prefix"`
+	Reason    string       `json:"reason"`
+	CreatedAt time.Time    `json:"createdAt"`
+	ExpiresAt time.Time    `json:"expiresAt"`
+}
+
+type CreateBanOpts struct {
+	Prefix   netip.Prefix
+	Reason   string
+	Duration time.Duration
+}
+
+type BanList interface {
+	Create(context.Context, CreateBanOpts) (Ban, error)
+	GetActive(context.Context) ([]Ban, error)
+	Delete(context.Context, int64) error
+}
+
+type StrikeConfig struct {
+	Threshold int           `yaml:"threshold"`
+	Window    time.Duration `yaml:"window"`
+}
+
+type Config struct {
+	Allow           []string      `yaml:"allow"`
+	Deny            []string      `yaml:"deny"`
+	RefreshInterval time.Duration `yaml:"refreshInterval"`
+	AutoBan         struct {
+		Duration        time.Duration `yaml:"duration"`
+		TooManyRequests StrikeConfig  `yaml:"tooManyRequests"`
+		FailedLogin     StrikeConfig  `yaml:"failedLogin"`
+	} `yaml:"autoBan"`
+	DB       BanList
+	ErrorLog *log.Logger
+}
+
+type Service interface {
+	Check(netip.Addr) Verdict
+	Strike(context.Context, netip.Addr, string) (bool, error)
+	Ban(context.Context, netip.Prefix, time.Duration, string) (Ban, error)
+	Unban(context.Context, int64) error
+	Bans(context.Context) ([]Ban, error)
+	Refresh(context.Context) error
+	Close()
+}
+
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		prefix, err := ParsePrefix(value)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+func ParsePrefix(value string) (netip.Prefix, error) {
+	value = strings.TrimSpace(value)
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("%w: %s", ErrInvalidPrefix, value)
+		}
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil || addr.Zone() != "" {
+		return netip.Prefix{}, fmt.Errorf("%w: %s", ErrInvalidPrefix, value)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func NewService(cfg *Config) (Service, error) {
+	if cfg.DB == nil {
+		return nil, ErrMissingBanDB
+	}
+	allow, err := parsePrefixes(cfg.Allow)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := parsePrefixes(cfg.Deny)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ErrorLog == nil {
+		cfg.ErrorLog = log.Default()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &service{
+		cfg:     cfg,
+		allow:   allow,
+		deny:    deny,
+		strikes: make(map[strikeKey]*strike),
+		cancel:  cancel,
+	}
+	if err = s.Refresh(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+	s.wg.Add(1)
+	go s.watch(ctx)
+	return s, nil
+}
+
+type strikeKey struct {
+	addr netip.Addr
+	kind string
+}
+
+type strike struct {
+	count   int
+	resetAt time.Time
+}
+
+type service struct {
+	cfg     *Config
+	allow   []netip.Prefix
+	deny    []netip.Prefix
+	bans    []Ban
+	strikes map[strikeKey]*strike
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	mutex   sync.RWMutex
+}
+
+func (s *service) Check(addr netip.Addr) Verdict {
+	addr = addr.Unmap()
+	if contains(s.allow, addr) {
+		return Exempt
+	}
+	if contains(s.deny, addr) {
+		return Block
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	now := time.Now()
+	for _, ban := range s.bans {
+		if ban.Prefix.Contains(addr) && ban.ExpiresAt.After(now) {
+			return Block
+		}
+	}
+	return Pass
+}
+
+func (s *service) strikeConfig(kind string) (StrikeConfig, error) {
+	switch kind {
+	case StrikeTooManyRequests:
+		return s.cfg.AutoBan.TooManyRequests, nil
+	case StrikeFailedLogin:
+		return s.cfg.AutoBan.FailedLogin, nil
+	default:
+		return StrikeConfig{}, fmt.Errorf("%w: %s", ErrUnknownStrike, kind)
+	}
+}
+
+func (s *service) Strike(ctx context.Context, addr netip.Addr, kind string) (bool, error) {
+	cfg, err := s.strikeConfig(kind)
+	if err != nil {
+		return false, err
+	}
+	addr = addr.Unmap()
+	if cfg.Threshold < 1 || s.cfg.AutoBan.Duration <= 0 || contains(s.allow, addr) {
+		return false, nil
+	}
+	s.mutex.Lock()
+	now := time.Now()
+	key := strikeKey{addr, kind}
+	st, ok := s.strikes[key]
+	if !ok || !now.Before(st.resetAt) {
+		st = &strike{resetAt: now.Add(cfg.Window)}
+		s.strikes[key] = st
+	}
+	st.count++
+	exceeded := st.count >= cfg.Threshold
+	if exceeded {
+		delete(s.strikes, key)
+	}
+	s.mutex.Unlock()
+	if !exceeded {
+		return false, nil
+	}
+	if _, err = s.Ban(ctx, netip.PrefixFrom(addr, addr.BitLen()), s.cfg.AutoBan.Duration, "auto: "+kind); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *service) Ban(ctx context.Context, prefix netip.Prefix, duration time.Duration, reason string) (Ban, error) {
+	if duration <= 0 {
+		return Ban{}, ErrInvalidBan
+	}
+	ban, err := s.cfg.DB.Create(ctx, CreateBanOpts{
+		Prefix:   prefix,
+		Reason:   reason,
+		Duration: duration,
+	})
+	if err != nil {
+		return Ban{}, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.bans = append(s.bans, ban)
+	return ban, nil
+}
+
+func (s *service) Unban(ctx context.Context, id int64) error {
+	if err := s.cfg.DB.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i, ban := range s.bans {
+		if ban.Id == id {
+			s.bans = append(s.bans[:i:i], s.bans[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (s *service) Bans(ctx context.Context) ([]Ban, error) {
+	return s.cfg.DB.GetActive(ctx)
+}
+
+func (s *service) Refresh(ctx context.Context) error {
+	bans, err := s.cfg.DB.GetActive(ctx)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.bans = bans
+	now := time.Now()
+	for key, st := range s.strikes {
+		if !now.Before(st.resetAt) {
+			delete(s.strikes, key)
+		}
+	}
+	return nil
+}
+
+func (s *service) watch(ctx context.Context) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(max(s.cfg.RefreshInterval, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refreshCtx, cancel := context.WithTimeout(ctx, refreshTimeout)
+			if err := s.Refresh(refreshCtx); err != nil && ctx.Err() == nil {
+				s.cfg.ErrorLog.Print(err)
+			}
+			cancel()
+		}
+	}
+}
+
+func (s *service) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
@@ -0,0 +1,154 @@
+package firewall
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+type banDB struct {
+	bans []Ban
+}
+
+func (db *banDB) Create(ctx context.Context, opts CreateBanOpts) (Ban, error) {
+	now := time.Now()
+	ban := Ban{
+		Id:        int64(len(db.bans) + 1),
+		Prefix:    opts.Prefix.Masked(),
+		Reason:    opts.Reason,
+		CreatedAt: now,
+		ExpiresAt: now.Add(opts.Duration),
+	}
+	db.bans = append(db.bans, ban)
+	return ban, nil
+}
+
+func (db *banDB) GetActive(ctx context.Context) ([]Ban, error) {
+	var bans []Ban
+	for _, ban := range db.bans {
+		if ban.ExpiresAt.After(time.Now()) {
+			bans = append(bans, ban)
+		}
+	}
+	return bans, nil
+}
+
+func (db *banDB) Delete(ctx context.Context, id int64) error {
+	for i, ban := range db.bans {
+		if ban.Id == id {
+			db.bans = append(db.bans[:i], db.bans[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotBanned
+}
+
+func TestParsePrefix(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+		err      error
+	}{
+		{"192.0.2.1", "192.0.2.1/32", nil},
+		{" 10.1.2.3/8 ", "10.0.0.0/8", nil},
+		{"::ffff:192.0.2.1", "192.0.2.1/32", nil},
+		{"::ffff:192.0.2.0/120", "192.0.2.0/24", nil},
+		{"2001:db8::1/32", "2001:db8::/32", nil},
+		{"fe80::1%eth0", "", ErrInvalidPrefix},
+		{"10.0.0.0/33", "", ErrInvalidPrefix},
+		{"example.com", "", ErrInvalidPrefix},
+	}
+	for _, test := range tests {
+		prefix, err := ParsePrefix(test.value)
+		if !errors.Is(err, test.err) {
+			t.Fatalf("expected error: %v, got: %v", test.err, err)
+		}
+		if err == nil && prefix.String() != test.expected {
+			t.Fatalf("expected prefix: %s, got: %s", test.expected, prefix)
+		}
+	}
+}
+
+func TestService(t *testing.T) {
+	db := &banDB{}
+	cfg := &Config{
+		Allow:           []string{"10.0.0.0/8"},
+		Deny:            []string{"198.51.100.0/24"},
+		RefreshInterval: time.Minute,
+		DB:              db,
+	}
+	cfg.AutoBan.Duration = time.Hour
+	cfg.AutoBan.FailedLogin = StrikeConfig{Threshold: 3, Window: time.Minute}
+	s, err := NewService(cfg)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	ban, err := s.Ban(ctx, netip.MustParsePrefix("203.0.113.0/24"), time.Hour, "abuse")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err = s.Ban(ctx, netip.MustParsePrefix("203.0.113.0/24"), 0, "abuse"); err != ErrInvalidBan {
+		t.Fatalf("expected error: %v, got: %v", ErrInvalidBan, err)
+	}
+	addr := netip.MustParseAddr("192.0.2.1")
+	for i, expected := range []bool{false, false, true} {
+		banned, err := s.Strike(ctx, addr, StrikeFailedLogin)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if banned != expected {
+			t.Fatalf("expected banned: %v on strike %d, got: %v", expected, i+1, banned)
+		}
+	}
+	for range 5 {
+		if banned, _ := s.Strike(ctx, netip.MustParseAddr("10.0.0.1"), StrikeFailedLogin); banned {
+			t.Fatalf("expected banned: %v, got: %v", false, banned)
+		}
+		if banned, _ := s.Strike(ctx, netip.MustParseAddr("192.0.2.2"), StrikeTooManyRequests); banned {
+			t.Fatalf("expected banned: %v, got: %v", false, banned)
+		}
+	}
+	if _, err = s.Strike(ctx, addr, "unknown"); !errors.Is(err, ErrUnknownStrike) {
+		t.Fatalf("expected error: %v, got: %v", ErrUnknownStrike, err)
+	}
+	tests := []struct {
+		addr     string
+		expected Verdict
+	}{
+		{"10.1.2.3", Exempt},
+		{"198.51.100.7", Block},
+		{"203.0.113.9", Block},
+		{"::ffff:203.0.113.9", Block},
+		{"192.0.2.1", Block},
+		{"192.0.2.2", Pass},
+		{"2001:db8::1", Pass},
+	}
+	for _, test := range tests {
+		if verdict := s.Check(netip.MustParseAddr(test.addr)); verdict != test.expected {
+			t.Fatalf("expected verdict: %d for %s, got: %d", test.expected, test.addr, verdict)
+		}
+	}
+	if err = s.Unban(ctx, ban.Id); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err = s.Unban(ctx, ban.Id); err != ErrNotBanned {
+		t.Fatalf("expected error: %v, got: %v", ErrNotBanned, err)
+	}
+	if verdict := s.Check(netip.MustParseAddr("203.0.113.9")); verdict != Pass {
+		t.Fatalf("expected verdict: %d, got: %d", Pass, verdict)
+	}
+	if err = s.Refresh(ctx); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	bans, err := s.Bans(ctx)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(bans) != 1 || bans[0].Prefix != netip.MustParsePrefix("192.0.2.1/32") {
+		t.Fatalf("expected bans: %d, got: %v", 1, bans)
+	}
+}
@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cyberwlodarczyk/auth/api/firewall"
+	"github.com/cyberwlodarczyk/auth/api/smtp"
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminConfig struct {
+	Errors      AdminErrors
+	Root        *Service
+	Firewall    firewall.Service
+	Suppression smtp.SuppressionList
+	Outbox      *smtp.MemoryService
+	Secret      string
+}
+
+type AdminErrors struct {
+	BadSecret     string `yaml:"badSecret"`
+	BadPrefix     string `yaml:"badPrefix"`
+	BadDuration   string `yaml:"badDuration"`
+	BanNotFound   string `yaml:"banNotFound"`
+	NotSuppressed string `yaml:"notSuppressed"`
+}
+
+type AdminService struct {
+	errBadSecret     error
+	errBadPrefix     error
+	errBadDuration   error
+	errBanNotFound   error
+	errNotSuppressed error
+	root             *Service
+	firewall         firewall.Service
+	suppression      smtp.SuppressionList
+	outbox           *smtp.MemoryService
+	secret           []byte
+}
+
+func NewAdminService(cfg *AdminConfig) *AdminService {
+	return &AdminService{
+		errBadSecret:     &operationalError{http.StatusUnauthorized, cfg.Errors.BadSecret},
+		errBadPrefix:     &operationalError{http.StatusBadRequest, cfg.Errors.BadPrefix},
+		errBadDuration:   &operationalError{http.StatusBadRequest, cfg.Errors.BadDuration},
+		errBanNotFound:   &operationalError{http.StatusNotFound, cfg.Errors.BanNotFound},
+		errNotSuppressed: &operationalError{http.StatusNotFound, cfg.Errors.NotSuppressed},
+		root:             cfg.Root,
+		firewall:         cfg.Firewall,
+		suppression:      cfg.Suppression,
+		outbox:           cfg.Outbox,
+		secret:           []byte(cfg.Secret),
+	}
+}
+
+func (s *AdminService) WithSecret(h http.Handler) http.Handler {
+	return s.root.createMiddleware(func(h http.Handler, w http.ResponseWriter, r *http.Request) error {
+		if !isAuthorized(r, s.secret) {
+			return s.errBadSecret
+		}
+		h.ServeHTTP(w, r)
+		return nil
+	})(h)
+}
+
+func (s *AdminService) GetBans() http.HandlerFunc {
+	type payload struct {
+		Bans []firewall.Ban `json:"bans"`
+	}
+	return s.root.createHandler(func(w http.ResponseWriter, r *http.Request) (res response, err error) {
+		bans, err := s.firewall.Bans(r.Context())
+		if err != nil {
+			return
+		}
+		if bans == nil {
+			bans = []firewall.Ban{}
+		}
+		res = response{http.StatusOK, payload{bans}}
+		return
+	})
+}
+
+func (s *AdminService) CreateBan() http.HandlerFunc {
+	type body struct {
+		Prefix   string `json:"prefix"`
+		Duration string `json:"duration"`
+		Reason   string `json:"reason"`
+	}
+	return s.root.createHandler(func(w http.ResponseWriter, r *http.Request) (res response, err error) {
+		var body body
+		if err = s.root.decodeJSONBody(r, &body); err != nil {
+			return
+		}
+		prefix, err := firewall.ParsePrefix(body.Prefix)
+		if err != nil {
+			err = s.errBadPrefix
+			return
+		}
+		duration, err := time.ParseDuration(body.Duration)
+		if err != nil || duration <= 0 {
+			err = s.errBadDuration
+			return
+		}
+		ban, err := s.firewall.Ban(r.Context(), prefix, duration, body.Reason)
+		if err != nil {
+			return
+		}
+		res = response{http.StatusCreated, ban}
+		return
+	})
+}
+
+func (s *AdminService) DeleteBan() http.HandlerFunc {
+	return s.root.createHandler(func(w http.ResponseWriter, r *http.Request) (res response, err error) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			err = s.errBanNotFound
+			return
+		}
+		if err = s.firewall.Unban(r.Context(), id); err != nil {
+			if errors.Is(err, firewall.ErrNotBanned) {
+				err = s.errBanNotFound
+			}
+			return
+		}
+		res = response{http.StatusNoContent, nil}
+		return
+	})
+}
+
+func (s *AdminService) DeleteSuppression() http.HandlerFunc {
+	return s.root.createHandler(func(w http.ResponseWriter, r *http.Request) (res response, err error) {
+		if err = s.suppression.Remove(r.Context(), chi.URLParam(r, "email")); err != nil {
+			if errors.Is(err, smtp.ErrNotSuppressed) {
+				err = s.errNotSuppressed
+			}
+			return
+		}
+		res = response{http.StatusNoContent, nil}
+		return
+	})
+}
+
+func (s *AdminService) GetMails() http.HandlerFunc {
+	type mail struct {
+		From      string    `json:"from"`
+		To        string    `json:"to"`
+		Subject   string    `json:"subject"`
+		Date      time.Time `json:"date"`
+		MessageID string    `json:"messageId"`
+		Text      string    `json:"text"`
+		HTML      string    `json:"html"`
+	}
+	type payload struct {
+		Mails []mail `json:"mails"`
+	}
+	return s.root.createHandler(func(w http.ResponseWriter, r *http.Request) (res response, err error) {
+		to := r.URL.Query().Get("to")
+		mails := []mail{}
+		for _, m := range s.outbox.Messages() {
+			if to != "" && !strings.EqualFold(m.To.Address, to) {
+				continue
+			}
+			mails = append(mails, mail{
+				From:      m.From.String(),
+				To:        m.To.String(),
+				Subject:   m.Subject,
+				Date:      m.Date,
+				MessageID: m.MessageID,
+				Text:      m.Text,
+				HTML:      m.HTML,
+			})
+		}
+		res = response{http.StatusOK, payload{mails}}
+		return
+	})
+}
+
+func (s *AdminService) DeleteMails() http.HandlerFunc {
+	return s.root.createHandler(func(w http.ResponseWriter, r *http.Request) (res response, err error) {
+		s.outbox.Reset()
+		res = response{http.StatusNoContent, nil}
+		return
+	})
+}
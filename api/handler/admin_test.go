@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cyberwlodarczyk/auth/api/smtp"
+	"github.com/go-chi/chi/v5"
+)
+
+type suppressionList map[string]bool
+
+func (l suppressionList) Suppress(ctx context.Context, opts smtp.SuppressOpts) error {
+	l[strings.ToLower(opts.Email)] = true
+	return nil
+}
+
+func (l suppressionList) IsSuppressed(ctx context.Context, email string) (bool, error) {
+	return l[strings.ToLower(email)], nil
+}
+
+func (l suppressionList) Remove(ctx context.Context, email string) error {
+	if !l[strings.ToLower(email)] {
+		return smtp.ErrNotSuppressed
+	}
+	delete(l, strings.ToLower(email))
+	return nil
+}
+
+func TestAdminDeleteSuppression(t *testing.T) {
+	list := suppressionList{"bob@example.com": true}
+	admin := NewAdminService(&AdminConfig{
+		Root:        NewService(&Config{}),
+		Suppression: list,
+		Secret:      "secret",
+	})
+	r := chi.NewRouter()
+	r.With(admin.WithSecret).Delete("/suppressions/{email}", admin.DeleteSuppression())
+	tests := []struct {
+		secret string
+		email  string
+		status int
+	}{
+		{"wrong", "bob@example.com", http.StatusUnauthorized},
+		{"secret", "Bob@Example.com", http.StatusNoContent},
+		{"secret", "bob@example.com", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodDelete, "/suppressions/"+test.email, nil)
+		req.Header.Set("Authorization", "Bearer "+test.secret)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != test.status {
+			t.Fatalf("expected status: %d for %s, got: %d", test.status, test.email, w.Code)
+		}
+	}
+	if len(list) != 0 {
+		t.Fatalf("expected empty suppression list, got: %v", list)
+	}
+}
@@ -2,27 +2,37 @@ package handler
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"io"
-	"net"
 	"net/http"
+	"net/netip"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/cyberwlodarczyk/auth/api/challenge"
+	"github.com/cyberwlodarczyk/auth/api/firewall"
 	"github.com/cyberwlodarczyk/auth/api/jwt"
 	"github.com/cyberwlodarczyk/auth/api/ratelimit"
+	"github.com/cyberwlodarczyk/auth/api/validation"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
 
+const ChallengeHeader = "X-Challenge-Response"
+
 type contextKey int
 
 const (
 	contextKeyRequestID contextKey = iota
 	contextKeyRequestTime
 	contextKeyUserID
+	contextKeyClientIP
+	contextKeyExempt
+	contextKeyUserPolicies
 )
 
 func createContextHelpers[T any](key contextKey) (get func(*http.Request) T, is func(*http.Request) bool, set func(*http.Request, T) *http.Request) {
@@ -40,15 +50,28 @@ func createContextHelpers[T any](key contextKey) (get func(*http.Request) T, is
 }
 
 var (
-	getRequestID, isRequestID, setRequestID       = createContextHelpers[string](contextKeyRequestID)
-	getRequestTime, isRequestTime, setRequestTime = createContextHelpers[time.Time](contextKeyRequestTime)
-	getUserID, isUserID, setUserID                = createContextHelpers[int64](contextKeyUserID)
+	getRequestID, isRequestID, setRequestID          = createContextHelpers[string](contextKeyRequestID)
+	getRequestTime, isRequestTime, setRequestTime    = createContextHelpers[time.Time](contextKeyRequestTime)
+	getUserID, isUserID, setUserID                   = createContextHelpers[int64](contextKeyUserID)
+	getClientIP, isClientIP, setClientIP             = createContextHelpers[string](contextKeyClientIP)
+	_, isExempt, setExempt                           = createContextHelpers[struct{}](contextKeyExempt)
+	getUserPolicies, isUserPolicies, setUserPolicies = createContextHelpers[[]*policy](contextKeyUserPolicies)
 )
 
 type message struct {
 	Message string `json:"message"`
 }
 
+type fieldError struct {
+	Field string `json:"field"`
+	validation.Violation
+}
+
+type fieldsMessage struct {
+	Message string       `json:"message"`
+	Fields  []fieldError `json:"fields"`
+}
+
 type response struct {
 	status  int
 	payload any
@@ -63,6 +86,38 @@ func (e *operationalError) Error() string {
 	return e.message
 }
 
+type validationError struct {
+	err    error
+	fields []fieldError
+}
+
+func (e *validationError) Error() string {
+	return e.err.Error()
+}
+
+func (e *validationError) Unwrap() error {
+	return e.err
+}
+
+func validate(err error, field string, violations []validation.Violation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	fields := make([]fieldError, 0, len(violations))
+	for _, v := range violations {
+		fields = append(fields, fieldError{field, v})
+	}
+	return &validationError{err, fields}
+}
+
+func isAuthorized(r *http.Request, secret []byte) bool {
+	header := strings.Split(r.Header.Get("Authorization"), " ")
+	return len(secret) != 0 &&
+		len(header) == 2 &&
+		header[0] == "Bearer" &&
+		subtle.ConstantTimeCompare([]byte(header[1]), secret) == 1
+}
+
 func isJWTErrorOperational(err error) bool {
 	return errors.Is(err, jwt.ErrExceededExpiration) ||
 		errors.Is(err, jwt.ErrInvalidFormat) ||
@@ -71,7 +126,11 @@ func isJWTErrorOperational(err error) bool {
 }
 
 type Config struct {
-	Errors Errors
+	Errors          Errors
+	TrustedProxies  []netip.Prefix
+	ForwardedHeader string
+	Firewall        firewall.Service
+	Challenge       challenge.Verifier
 }
 
 type Errors struct {
@@ -82,6 +141,10 @@ type Errors struct {
 	BodyMalformed     string `yaml:"bodyMalformed"`
 	BadBodyEncoding   string `yaml:"badBodyEncoding"`
 	TooManyRequests   string `yaml:"tooManyRequests"`
+	Forbidden         string `yaml:"forbidden"`
+	ChallengeRequired string `yaml:"challengeRequired"`
+	Unavailable       string `yaml:"unavailable"`
+	BadForwarded      string `yaml:"badForwarded"`
 }
 
 type Service struct {
@@ -92,6 +155,14 @@ type Service struct {
 	errMalformedBody     error
 	errBadBodyEncoding   error
 	errTooManyRequests   error
+	errForbidden         error
+	errChallengeRequired error
+	errUnavailable       error
+	errBadForwarded      error
+	trustedProxies       []netip.Prefix
+	forwardedHeader      string
+	firewall             firewall.Service
+	challenge            challenge.Verifier
 }
 
 func NewService(cfg *Config) *Service {
@@ -103,6 +174,14 @@ func NewService(cfg *Config) *Service {
 		errMalformedBody:     &operationalError{http.StatusBadRequest, cfg.Errors.BodyMalformed},
 		errBadBodyEncoding:   &operationalError{http.StatusUnsupportedMediaType, cfg.Errors.BadBodyEncoding},
 		errTooManyRequests:   &operationalError{http.StatusTooManyRequests, cfg.Errors.TooManyRequests},
+		errForbidden:         &operationalError{http.StatusForbidden, cfg.Errors.Forbidden},
+		errChallengeRequired: &operationalError{http.StatusPreconditionRequired, cfg.Errors.ChallengeRequired},
+		errUnavailable:       &operationalError{http.StatusServiceUnavailable, cfg.Errors.Unavailable},
+		errBadForwarded:      &operationalError{http.StatusBadRequest, cfg.Errors.BadForwarded},
+		trustedProxies:       cfg.TrustedProxies,
+		forwardedHeader:      cfg.ForwardedHeader,
+		firewall:             cfg.Firewall,
+		challenge:            cfg.Challenge,
 	}
 }
 
@@ -143,17 +222,50 @@ func (s *Service) WithBodyLimit(bytes int64) func(http.Handler) http.Handler {
 	}
 }
 
-func (s *Service) WithRateLimit(limiter ratelimit.Limiter) func(http.Handler) http.Handler {
-	return s.createMiddleware(func(h http.Handler, w http.ResponseWriter, r *http.Request) error {
-		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
+}
+
+func (s *Service) limit(w http.ResponseWriter, r *http.Request, limiter ratelimit.Limiter, key string) error {
+	result, err := limiter.Allow(r.Context(), key)
+	if err != nil {
+		return err
+	}
+	header := w.Header()
+	remaining, err := strconv.Atoi(header.Get("RateLimit-Remaining"))
+	if err != nil || !result.Allowed || result.Remaining < remaining {
+		header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		header.Set("RateLimit-Reset", seconds(result.Reset))
+	}
+	if !result.Allowed {
+		header.Set("Retry-After", seconds(result.RetryAfter))
+		s.strike(r, firewall.StrikeTooManyRequests)
+		return s.errTooManyRequests
+	}
+	return nil
+}
+
+func (s *Service) requireChallenge(r *http.Request, risk ratelimit.Limiter, key string) error {
+	result, err := risk.Allow(r.Context(), key)
+	if err != nil || result.Allowed {
+		return err
+	}
+	err = s.challenge.Verify(r.Context(), r.Header.Get(ChallengeHeader), clientIP(r))
+	if errors.Is(err, challenge.ErrInvalidResponse) {
+		return s.errChallengeRequired
+	}
+	return err
+}
+
+func (s *Service) Challenge() http.HandlerFunc {
+	return s.createHandler(func(w http.ResponseWriter, r *http.Request) (res response, err error) {
+		c, err := s.challenge.Issue(r.Context())
 		if err != nil {
-			return err
+			return
 		}
-		if !limiter.Allow(ip) {
-			return s.errTooManyRequests
-		}
-		h.ServeHTTP(w, r)
-		return nil
+		res = response{http.StatusOK, c}
+		return
 	})
 }
 
@@ -196,11 +308,13 @@ func (s *Service) decodeJSONBody(r *http.Request, v any) error {
 func (s *Service) reply(w http.ResponseWriter, r *http.Request, res response, err error) {
 	if err != nil {
 		var operationalErr *operationalError
+		var validationErr *validationError
 		if errors.As(err, &operationalErr) {
-			res = response{
-				operationalErr.status,
-				message{operationalErr.message},
+			var payload any = message{operationalErr.message}
+			if errors.As(err, &validationErr) {
+				payload = fieldsMessage{operationalErr.message, validationErr.fields}
 			}
+			res = response{operationalErr.status, payload}
 		} else {
 			res = response{
 				http.StatusInternalServerError,
@@ -225,7 +339,7 @@ func (s *Service) reply(w http.ResponseWriter, r *http.Request, res response, er
 		w.WriteHeader(res.status)
 	}
 	fields := logrus.Fields{
-		"ip":     strings.Split(r.RemoteAddr, ":")[0],
+		"ip":     clientIP(r),
 		"method": r.Method,
 		"path":   r.URL.EscapedPath(),
 		"status": res.status,
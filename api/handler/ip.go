@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/cyberwlodarczyk/auth/api/firewall"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ForwardedHeaderXForwardedFor = "x-forwarded-for"
+	ForwardedHeaderForwarded     = "forwarded"
+)
+
+var (
+	ErrInvalidProxy           = errors.New("handler: trusted proxy must be an ip address or cidr")
+	ErrUnknownForwardedHeader = errors.New("handler: forwarded header must be x-forwarded-for or forwarded")
+)
+
+func ParseForwardedHeader(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case ForwardedHeaderXForwardedFor:
+		return "X-Forwarded-For", nil
+	case ForwardedHeaderForwarded:
+		return "Forwarded", nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownForwardedHeader, value)
+	}
+}
+
+func ParseTrustedProxies(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		prefix, err := firewall.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidProxy, value)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+func parseAddr(value string) (netip.Addr, bool) {
+	value = strings.TrimSpace(value)
+	if addrPort, err := netip.ParseAddrPort(value); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	addr, err := netip.ParseAddr(value)
+	if err != nil || addr.Zone() != "" {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+func forwardedFor(r *http.Request, header string) []string {
+	var chain []string
+	for _, value := range r.Header.Values(header) {
+		for _, element := range strings.Split(value, ",") {
+			if header != "Forwarded" {
+				chain = append(chain, element)
+				continue
+			}
+			node := ""
+			for _, pair := range strings.Split(element, ";") {
+				key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(key, "for") {
+					node = strings.Trim(value, `"`)
+				}
+			}
+			chain = append(chain, node)
+		}
+	}
+	return chain
+}
+
+func (s *Service) isTrustedProxy(addr netip.Addr) bool {
+	for _, prefix := range s.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) resolveClientIP(r *http.Request) (string, error) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, ok := parseAddr(host)
+	if !ok {
+		return host, nil
+	}
+	if !s.isTrustedProxy(addr) {
+		return addr.String(), nil
+	}
+	chain := forwardedFor(r, s.forwardedHeader)
+	for i := len(chain) - 1; i >= 0; i-- {
+		if addr, ok = parseAddr(chain[i]); !ok {
+			return "", s.errBadForwarded
+		}
+		if !s.isTrustedProxy(addr) {
+			break
+		}
+	}
+	return addr.String(), nil
+}
+
+func clientIP(r *http.Request) string {
+	if isClientIP(r) {
+		return getClientIP(r)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (s *Service) WithClientIP(h http.Handler) http.Handler {
+	return s.createMiddleware(func(h http.Handler, w http.ResponseWriter, r *http.Request) error {
+		ip, err := s.resolveClientIP(r)
+		if err != nil {
+			return err
+		}
+		h.ServeHTTP(w, setClientIP(r, ip))
+		return nil
+	})(h)
+}
+
+func (s *Service) strike(r *http.Request, kind string) {
+	if s.firewall == nil {
+		return
+	}
+	addr, ok := parseAddr(clientIP(r))
+	if !ok {
+		return
+	}
+	banned, err := s.firewall.Strike(r.Context(), addr, kind)
+	if err != nil {
+		logrus.WithField("ip", addr.String()).WithField("kind", kind).Error(err)
+		return
+	}
+	if banned {
+		logrus.WithField("ip", addr.String()).WithField("kind", kind).Warn("address banned")
+	}
+}
+
+func (s *Service) WithFirewall() func(http.Handler) http.Handler {
+	return s.createMiddleware(func(h http.Handler, w http.ResponseWriter, r *http.Request) error {
+		addr, ok := parseAddr(clientIP(r))
+		if !ok {
+			h.ServeHTTP(w, r)
+			return nil
+		}
+		switch s.firewall.Check(addr) {
+		case firewall.Block:
+			return s.errForbidden
+		case firewall.Exempt:
+			r = setExempt(r, struct{}{})
+		}
+		h.ServeHTTP(w, r)
+		return nil
+	})
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestParseForwardedHeader(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+		err      error
+	}{
+		{"x-forwarded-for", "X-Forwarded-For", nil},
+		{"Forwarded", "Forwarded", nil},
+		{"", "", ErrUnknownForwardedHeader},
+		{"x-real-ip", "", ErrUnknownForwardedHeader},
+	}
+	for _, test := range tests {
+		header, err := ParseForwardedHeader(test.value)
+		if !errors.Is(err, test.err) || header != test.expected {
+			t.Fatalf("expected header: %q and error: %v for %q, got: %q, %v", test.expected, test.err, test.value, header, err)
+		}
+	}
+}
+
+func TestForwardedFor(t *testing.T) {
+	tests := []struct {
+		header    string
+		forwarded []string
+		xff       []string
+		expected  []string
+	}{
+		{"X-Forwarded-For", nil, nil, nil},
+		{"X-Forwarded-For", []string{"for=203.0.113.9"}, []string{"192.0.2.1, 10.0.0.1", "10.0.0.2"}, []string{"192.0.2.1", " 10.0.0.1", "10.0.0.2"}},
+		{"Forwarded", nil, []string{"198.51.100.1"}, nil},
+		{"Forwarded", []string{`for=192.0.2.43, for="[2001:db8:cafe::17]:4711"`}, []string{"198.51.100.1"}, []string{"192.0.2.43", "[2001:db8:cafe::17]:4711"}},
+		{"Forwarded", []string{`proto=https;For="192.0.2.60:8080";by=203.0.113.43`}, nil, []string{"192.0.2.60:8080"}},
+		{"Forwarded", []string{"for=192.0.2.1", "proto=http, for=10.0.0.1"}, nil, []string{"192.0.2.1", "", "10.0.0.1"}},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, value := range test.forwarded {
+			r.Header.Add("Forwarded", value)
+		}
+		for _, value := range test.xff {
+			r.Header.Add("X-Forwarded-For", value)
+		}
+		if chain := forwardedFor(r, test.header); !slices.Equal(chain, test.expected) {
+			t.Fatalf("expected chain: %q from %s, got: %q", test.expected, test.header, chain)
+		}
+	}
+}
+
+func TestResolveClientIP(t *testing.T) {
+	trustedProxies := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8:ffff::/48"),
+	}
+	xff := NewService(&Config{TrustedProxies: trustedProxies, ForwardedHeader: "X-Forwarded-For"})
+	forwarded := NewService(&Config{TrustedProxies: trustedProxies, ForwardedHeader: "Forwarded"})
+	tests := []struct {
+		svc        *Service
+		remoteAddr string
+		forwarded  string
+		xff        string
+		expected   string
+		err        bool
+	}{
+		{xff, "192.0.2.1:1234", "", "198.51.100.1", "192.0.2.1", false},
+		{forwarded, "192.0.2.1:1234", "for=198.51.100.1", "", "192.0.2.1", false},
+		{xff, "10.0.0.1:1234", "", "", "10.0.0.1", false},
+		{xff, "10.0.0.1:1234", "", "198.51.100.1", "198.51.100.1", false},
+		{xff, "10.0.0.1:1234", "for=203.0.113.9", "198.51.100.1", "198.51.100.1", false},
+		{xff, "10.0.0.1:1234", "for=203.0.113.9", "", "10.0.0.1", false},
+		{xff, "10.0.0.1:1234", "", "203.0.113.9, 198.51.100.1", "198.51.100.1", false},
+		{xff, "10.0.0.1:1234", "", "203.0.113.9, 198.51.100.1, 10.0.0.2", "198.51.100.1", false},
+		{xff, "10.0.0.1:1234", "", "10.0.0.3, 10.0.0.2", "10.0.0.3", false},
+		{xff, "10.0.0.1:1234", "", "garbage, 198.51.100.1", "198.51.100.1", false},
+		{xff, "10.0.0.1:1234", "", "garbage, 10.0.0.2", "", true},
+		{xff, "10.0.0.1:1234", "", "198.51.100.1, , 10.0.0.2", "", true},
+		{xff, "10.0.0.1:1234", "", "198.51.100.1:4711", "198.51.100.1", false},
+		{xff, "10.0.0.1:1234", "", "::ffff:198.51.100.1", "198.51.100.1", false},
+		{forwarded, "10.0.0.1:1234", `for="[2001:db8::1]:4711"`, "198.51.100.1", "2001:db8::1", false},
+		{forwarded, "10.0.0.1:1234", "", "198.51.100.1", "10.0.0.1", false},
+		{forwarded, "10.0.0.1:1234", `for=203.0.113.9, for="[2001:db8::1]"`, "", "2001:db8::1", false},
+		{forwarded, "10.0.0.1:1234", `for=203.0.113.9;proto=https, for=10.0.0.2`, "", "203.0.113.9", false},
+		{forwarded, "10.0.0.1:1234", `for=203.0.113.9, proto=https`, "", "", true},
+		{forwarded, "10.0.0.1:1234", `for=unknown`, "", "", true},
+		{forwarded, "10.0.0.1:1234", `for=_hidden, for=198.51.100.1`, "", "198.51.100.1", false},
+		{forwarded, "10.0.0.1:1234", `for="[fe80::1%eth0]"`, "", "", true},
+		{forwarded, "[2001:db8:ffff::1]:1234", `for="[2001:db8::1]:4711"`, "", "2001:db8::1", false},
+		{forwarded, "[2001:db8::2]:1234", `for="[2001:db8::1]:4711"`, "", "2001:db8::2", false},
+		{xff, "[::ffff:10.0.0.1]:1234", "", "198.51.100.1", "198.51.100.1", false},
+		{xff, "pipe", "", "198.51.100.1", "pipe", false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = test.remoteAddr
+		if test.forwarded != "" {
+			r.Header.Set("Forwarded", test.forwarded)
+		}
+		if test.xff != "" {
+			r.Header.Set("X-Forwarded-For", test.xff)
+		}
+		ip, err := test.svc.resolveClientIP(r)
+		if (err != nil) != test.err || ip != test.expected {
+			t.Fatalf("expected ip: %q and error: %t for %s (forwarded: %q, x-forwarded-for: %q), got: %q, %v", test.expected, test.err, test.remoteAddr, test.forwarded, test.xff, ip, err)
+		}
+	}
+}
+
+func TestWithClientIP(t *testing.T) {
+	s := NewService(&Config{
+		TrustedProxies:  []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		ForwardedHeader: "X-Forwarded-For",
+	})
+	h := s.WithClientIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Client-IP", clientIP(r))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	tests := []struct {
+		xff      string
+		status   int
+		clientIP string
+	}{
+		{"198.51.100.1", http.StatusNoContent, "198.51.100.1"},
+		{"unknown", http.StatusBadRequest, ""},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-Forwarded-For", test.xff)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != test.status || w.Header().Get("X-Client-IP") != test.clientIP {
+			t.Fatalf("expected status: %d and ip: %q, got: %d, %q", test.status, test.clientIP, w.Code, w.Header().Get("X-Client-IP"))
+		}
+	}
+}
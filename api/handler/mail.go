@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/cyberwlodarczyk/auth/api/smtp"
+)
+
+type MailConfig struct {
+	Errors        MailErrors
+	Root          *Service
+	Suppression   smtp.SuppressionList
+	WebhookSecret string
+}
+
+type MailErrors struct {
+	BadSecret string `yaml:"badSecret"`
+	BadReport string `yaml:"badReport"`
+}
+
+type MailService struct {
+	errBadSecret  error
+	errBadReport  error
+	root          *Service
+	suppression   smtp.SuppressionList
+	webhookSecret []byte
+}
+
+func NewMailService(cfg *MailConfig) *MailService {
+	return &MailService{
+		errBadSecret:  &operationalError{http.StatusUnauthorized, cfg.Errors.BadSecret},
+		errBadReport:  &operationalError{http.StatusBadRequest, cfg.Errors.BadReport},
+		root:          cfg.Root,
+		suppression:   cfg.Suppression,
+		webhookSecret: []byte(cfg.WebhookSecret),
+	}
+}
+
+func (s *MailService) readReports(r *http.Request) ([]smtp.Report, error) {
+	mime := strings.ToLower(
+		strings.TrimSpace(
+			strings.Split(r.Header.Get("Content-Type"), ";")[0],
+		),
+	)
+	if mime == "message/rfc822" {
+		reports, err := smtp.ParseReport(r.Body)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				return nil, s.root.errExceededBodyLimit
+			}
+			return nil, s.errBadReport
+		}
+		return reports, nil
+	}
+	type body struct {
+		Reports []struct {
+			Kind      string `json:"kind"`
+			Recipient string `json:"recipient"`
+			Reason    string `json:"reason"`
+		} `json:"reports"`
+	}
+	var b body
+	if err := s.root.decodeJSONBody(r, &b); err != nil {
+		return nil, err
+	}
+	reports := make([]smtp.Report, 0, len(b.Reports))
+	for _, report := range b.Reports {
+		if report.Recipient == "" || (report.Kind != smtp.SuppressionBounce && report.Kind != smtp.SuppressionComplaint) {
+			return nil, s.errBadReport
+		}
+		reports = append(reports, smtp.Report{Recipient: report.Recipient, Kind: report.Kind, Reason: report.Reason})
+	}
+	return reports, nil
+}
+
+func (s *MailService) Webhook() http.HandlerFunc {
+	return s.root.createHandler(func(w http.ResponseWriter, r *http.Request) (res response, err error) {
+		if !isAuthorized(r, s.webhookSecret) {
+			err = s.errBadSecret
+			return
+		}
+		reports, err := s.readReports(r)
+		if err != nil {
+			return
+		}
+		for _, report := range reports {
+			if err = s.suppression.Suppress(r.Context(), smtp.SuppressOpts{
+				Email:  report.Recipient,
+				Kind:   report.Kind,
+				Reason: report.Reason,
+			}); err != nil {
+				return
+			}
+		}
+		res = response{http.StatusNoContent, nil}
+		return
+	})
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	netmail "net/mail"
+	"time"
+
+	"github.com/cyberwlodarczyk/auth/api/postgres"
+	"github.com/sirupsen/logrus"
+)
+
+type UserNotificationMail struct {
+	Template string `yaml:"template"`
+}
+
+type UserNotifications struct {
+	PasswordChanged UserNotificationMail `yaml:"passwordChanged"`
+	EmailChanged    UserNotificationMail `yaml:"emailChanged"`
+	AccountDeleted  UserNotificationMail `yaml:"accountDeleted"`
+	NewLogin        UserNotificationMail `yaml:"newLogin"`
+	SudoUsed        UserNotificationMail `yaml:"sudoUsed"`
+}
+
+type UserNotificationData struct {
+	Name      string
+	Email     string
+	NewEmail  string
+	IP        string
+	UserAgent string
+	Time      time.Time
+}
+
+const (
+	deviceCookieName   = "device"
+	deviceCookieMaxAge = 400 * 24 * time.Hour
+)
+
+func deviceFingerprint(w http.ResponseWriter, r *http.Request) (string, error) {
+	var token []byte
+	if cookie, err := r.Cookie(deviceCookieName); err == nil {
+		token, _ = base64.RawURLEncoding.DecodeString(cookie.Value)
+	}
+	if len(token) != 32 {
+		token = make([]byte, 32)
+		if _, err := rand.Read(token); err != nil {
+			return "", err
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     deviceCookieName,
+		Value:    base64.RawURLEncoding.EncodeToString(token),
+		Path:     "/",
+		MaxAge:   int(deviceCookieMaxAge.Seconds()),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	sum := sha256.Sum256(token)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (s *UserService) touchDevice(w http.ResponseWriter, r *http.Request, userID int64) (bool, error) {
+	fingerprint, err := deviceFingerprint(w, r)
+	if err != nil {
+		return false, err
+	}
+	return s.notifications.TouchDevice(r.Context(), userID, fingerprint)
+}
+
+func (s *UserService) notify(r *http.Request, mail UserNotificationMail, user postgres.User, newEmail string) {
+	tmpl, err := s.templates.Get(mail.Template, user.Locale)
+	if err == nil {
+		err = s.mail.Send(r.Context(), netmail.Address{Name: user.Name, Address: user.Email}, tmpl, UserNotificationData{
+			Name:      user.Name,
+			Email:     user.Email,
+			NewEmail:  newEmail,
+			IP:        clientIP(r),
+			UserAgent: r.UserAgent(),
+			Time:      time.Now().UTC(),
+		})
+	}
+	if err != nil {
+		logrus.WithField("template", mail.Template).WithField("userId", user.Id).Error(err)
+	}
+}
+
+func (s *UserService) notifyNewDevice(w http.ResponseWriter, r *http.Request, user postgres.User) {
+	created, err := s.touchDevice(w, r, user.Id)
+	if err != nil {
+		logrus.WithField("userId", user.Id).Error(err)
+		return
+	}
+	if !created {
+		return
+	}
+	prefs, err := s.notifications.GetPreferences(r.Context(), user.Id)
+	if err != nil {
+		logrus.WithField("userId", user.Id).Error(err)
+		return
+	}
+	if prefs.NewLogin {
+		s.notify(r, s.notificationMails.NewLogin, user, "")
+	}
+}
+
+func (s *UserService) GetNotifications() http.HandlerFunc {
+	type payload struct {
+		Notifications postgres.NotificationPreferences `json:"notifications"`
+	}
+	return s.root.createHandler(func(w http.ResponseWriter, r *http.Request) (res response, err error) {
+		prefs, err := s.notifications.GetPreferences(r.Context(), getUserID(r))
+		if err != nil {
+			return
+		}
+		res = response{http.StatusOK, payload{prefs}}
+		return
+	})
+}
+
+func (s *UserService) EditNotifications() http.HandlerFunc {
+	return s.root.createHandler(func(w http.ResponseWriter, r *http.Request) (res response, err error) {
+		var body postgres.NotificationPreferences
+		if err = s.decodeJSONBody(r, &body); err != nil {
+			return
+		}
+		if err = s.notifications.EditPreferences(r.Context(), getUserID(r), body); err != nil {
+			err = s.isNotFound(err)
+			return
+		}
+		res = response{http.StatusNoContent, nil}
+		return
+	})
+}
@@ -0,0 +1,292 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cyberwlodarczyk/auth/api/ratelimit"
+	"github.com/cyberwlodarczyk/auth/api/validation"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	PolicyKeyIP     = "ip"
+	PolicyKeyUser   = "user"
+	PolicyKeyEmail  = "email"
+	PolicyKeyHeader = "header"
+	PolicyKeyGlobal = "global"
+)
+
+const (
+	PolicyActionLimit     = "limit"
+	PolicyActionChallenge = "challenge"
+)
+
+var (
+	ErrMissingPolicyName    = errors.New("handler: rate limit policy name is required")
+	ErrMissingPolicyPattern = errors.New("handler: rate limit policy pattern must start with a slash")
+	ErrMissingPolicyField   = errors.New("handler: rate limit policy key requires a field")
+	ErrUnknownPolicyKey     = errors.New("handler: unknown rate limit policy key")
+	ErrUnknownPolicyAction  = errors.New("handler: unknown rate limit policy action")
+	ErrConflictingPolicy    = errors.New("handler: rate limit policies with the same name must have the same key, field, action and params")
+	ErrUnmatchedPolicy      = errors.New("handler: rate limit policy does not match any route")
+	ErrUnresolvedPolicyUser = errors.New("handler: rate limit policy keyed by user matches a route without a user session")
+)
+
+var errMalformedPolicyField = errors.New("handler: rate limit policy field is malformed")
+
+type RateLimitPolicy struct {
+	Name             string `yaml:"name"`
+	Method           string `yaml:"method"`
+	Pattern          string `yaml:"pattern"`
+	Key              string `yaml:"key"`
+	Field            string `yaml:"field"`
+	Action           string `yaml:"action"`
+	ratelimit.Params `yaml:",inline"`
+}
+
+func (p *RateLimitPolicy) validate() error {
+	if p.Name == "" {
+		return ErrMissingPolicyName
+	}
+	if !strings.HasPrefix(p.Pattern, "/") {
+		return ErrMissingPolicyPattern
+	}
+	switch p.Key {
+	case PolicyKeyIP, PolicyKeyUser, PolicyKeyGlobal:
+	case PolicyKeyEmail, PolicyKeyHeader:
+		if p.Field == "" {
+			return ErrMissingPolicyField
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownPolicyKey, p.Key)
+	}
+	switch p.Action {
+	case "", PolicyActionLimit, PolicyActionChallenge:
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownPolicyAction, p.Action)
+	}
+	return p.Params.Validate()
+}
+
+type userHandler struct {
+	http.Handler
+}
+
+func resolvesUser(middlewares []func(http.Handler) http.Handler) bool {
+	for _, m := range middlewares {
+		if _, ok := m(http.NotFoundHandler()).(userHandler); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type RateLimitConfig struct {
+	Service         ratelimit.Service
+	Policies        []RateLimitPolicy
+	EmailValidation validation.EmailService
+	BodyLimit       int64
+}
+
+type policy struct {
+	RateLimitPolicy
+	limiter ratelimit.Limiter
+}
+
+type policyRoute struct {
+	mux      *chi.Mux
+	policies []*policy
+}
+
+type policyRequest struct {
+	w     http.ResponseWriter
+	r     *http.Request
+	email validation.EmailService
+	limit int64
+	body  map[string]json.RawMessage
+	read  bool
+}
+
+func (p *policyRequest) field(name string) (string, error) {
+	if !p.read {
+		p.read = true
+		b, err := io.ReadAll(http.MaxBytesReader(p.w, p.r.Body, p.limit))
+		if err != nil {
+			return "", err
+		}
+		p.r.Body.Close()
+		p.r.Body = io.NopCloser(bytes.NewReader(b))
+		if err = json.Unmarshal(b, &p.body); err != nil {
+			return "", errMalformedPolicyField
+		}
+	}
+	if p.body == nil {
+		return "", errMalformedPolicyField
+	}
+	raw, ok := p.body[name]
+	if !ok {
+		return "", nil
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", errMalformedPolicyField
+	}
+	return value, nil
+}
+
+func (p *policyRequest) key(policy *policy) (string, error) {
+	switch policy.Key {
+	case PolicyKeyIP:
+		return clientIP(p.r), nil
+	case PolicyKeyUser:
+		if !isUserID(p.r) {
+			return "", nil
+		}
+		return strconv.FormatInt(getUserID(p.r), 16), nil
+	case PolicyKeyEmail:
+		value, err := p.field(policy.Field)
+		if err != nil || value == "" {
+			return "", err
+		}
+		return p.email.Normalize(value).Canonical, nil
+	case PolicyKeyGlobal:
+		return "*", nil
+	default:
+		return p.r.Header.Get(policy.Field), nil
+	}
+}
+
+func (s *Service) applyPolicies(p *policyRequest, policies []*policy) error {
+	for _, policy := range policies {
+		if policy.Method != "" && !strings.EqualFold(policy.Method, p.r.Method) {
+			continue
+		}
+		if isExempt(p.r) && (policy.Key == PolicyKeyIP || policy.Action == PolicyActionChallenge) {
+			continue
+		}
+		key, err := p.key(policy)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				return s.errExceededBodyLimit
+			}
+			if errors.Is(err, errMalformedPolicyField) {
+				return s.errMalformedBody
+			}
+			return err
+		}
+		if key == "" {
+			continue
+		}
+		if policy.Action == PolicyActionChallenge {
+			err = s.requireChallenge(p.r, policy.limiter, key)
+		} else {
+			err = s.limit(p.w, p.r, policy.limiter, key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) applyUserPolicies(w http.ResponseWriter, r *http.Request) error {
+	if !isUserPolicies(r) {
+		return nil
+	}
+	return s.applyPolicies(&policyRequest{w: w, r: r}, getUserPolicies(r))
+}
+
+func (s *Service) WithRateLimitPolicies(cfg *RateLimitConfig) (func(http.Handler) http.Handler, error) {
+	var routes []*policyRoute
+	patterns := make(map[string]*policyRoute)
+	limiters := make(map[string]*policy)
+	for _, p := range cfg.Policies {
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", p.Name, err)
+		}
+		limiter := limiters[p.Name]
+		if limiter == nil {
+			limiter = &policy{p, cfg.Service.NewLimiter(p.Name, p.Params)}
+			limiters[p.Name] = limiter
+		} else if limiter.Key != p.Key || limiter.Field != p.Field || limiter.Action != p.Action || limiter.Params != p.Params {
+			return nil, fmt.Errorf("%s: %w", p.Name, ErrConflictingPolicy)
+		}
+		route, ok := patterns[p.Pattern]
+		if !ok {
+			route = &policyRoute{mux: chi.NewRouter()}
+			route.mux.HandleFunc(p.Pattern, http.NotFound)
+			patterns[p.Pattern] = route
+			routes = append(routes, route)
+		}
+		route.policies = append(route.policies, &policy{p, limiter.limiter})
+	}
+	return s.createMiddleware(func(h http.Handler, w http.ResponseWriter, r *http.Request) error {
+		var immediate, deferred []*policy
+		for _, route := range routes {
+			if route.mux.Find(chi.NewRouteContext(), r.Method, r.URL.Path) == "" {
+				continue
+			}
+			for _, policy := range route.policies {
+				if policy.Key == PolicyKeyUser {
+					deferred = append(deferred, policy)
+				} else {
+					immediate = append(immediate, policy)
+				}
+			}
+		}
+		p := &policyRequest{w: w, r: r, email: cfg.EmailValidation, limit: cfg.BodyLimit}
+		if err := s.applyPolicies(p, immediate); err != nil {
+			return err
+		}
+		if len(deferred) > 0 {
+			r = setUserPolicies(r, deferred)
+		}
+		h.ServeHTTP(w, r)
+		return nil
+	}), nil
+}
+
+func CheckRateLimitPolicies(routes chi.Routes, policies []RateLimitPolicy) error {
+	type route struct {
+		method  string
+		pattern string
+		user    bool
+	}
+	var registered []route
+	if err := chi.Walk(routes, func(method, pattern string, h http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		_, ok := h.(userHandler)
+		registered = append(registered, route{method, pattern, ok || resolvesUser(middlewares)})
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, p := range policies {
+		mux := chi.NewRouter()
+		mux.HandleFunc(p.Pattern, http.NotFound)
+		matched := false
+		for _, r := range registered {
+			if p.Method != "" && !strings.EqualFold(p.Method, r.method) {
+				continue
+			}
+			if mux.Find(chi.NewRouteContext(), r.method, r.pattern) == "" {
+				continue
+			}
+			if p.Key == PolicyKeyUser && !r.user {
+				return fmt.Errorf("%s: %w: %s %s", p.Name, ErrUnresolvedPolicyUser, r.method, r.pattern)
+			}
+			matched = true
+		}
+		if !matched {
+			return fmt.Errorf("%s: %w: %s %s", p.Name, ErrUnmatchedPolicy, p.Method, p.Pattern)
+		}
+	}
+	return nil
+}
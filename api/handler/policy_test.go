@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyberwlodarczyk/auth/api/challenge"
+	"github.com/cyberwlodarczyk/auth/api/ratelimit"
+	"github.com/cyberwlodarczyk/auth/api/validation"
+	"github.com/go-chi/chi/v5"
+)
+
+type rejectingVerifier struct{}
+
+func (rejectingVerifier) Issue(context.Context) (challenge.Challenge, error) {
+	return challenge.Challenge{}, nil
+}
+
+func (rejectingVerifier) Verify(ctx context.Context, response, ip string) error {
+	return challenge.ErrInvalidResponse
+}
+
+func (rejectingVerifier) Close() {}
+
+type policyStep struct {
+	method string
+	path   string
+	ip     string
+	body   string
+	exempt bool
+	status int
+}
+
+func newPolicyHandler(t *testing.T, policies []RateLimitPolicy) http.Handler {
+	t.Helper()
+	email, err := validation.NewEmailService(&validation.EmailConfig{Pattern: "^.+@.+$"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	rl := ratelimit.NewService(time.Minute, time.Minute, 0)
+	t.Cleanup(rl.Close)
+	s := NewService(&Config{Challenge: rejectingVerifier{}})
+	mw, err := s.WithRateLimitPolicies(&RateLimitConfig{
+		Service:         rl,
+		Policies:        policies,
+		EmailValidation: email,
+		BodyLimit:       1024,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	return mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-User") != "" {
+			r = setUserID(r, 1)
+			if err := s.applyUserPolicies(w, r); err != nil {
+				s.reply(w, r, response{}, err)
+				return
+			}
+		}
+		if _, err := io.ReadAll(r.Body); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func runPolicySteps(t *testing.T, h http.Handler, steps []policyStep) {
+	t.Helper()
+	for i, step := range steps {
+		r := httptest.NewRequest(step.method, step.path, strings.NewReader(step.body))
+		r.RemoteAddr = step.ip + ":1234"
+		if step.exempt {
+			r = setExempt(r, struct{}{})
+		}
+		if step.method == http.MethodPut {
+			r.Header.Set("X-User", "1")
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != step.status {
+			t.Fatalf("expected status: %d on step %d, got: %d", step.status, i+1, w.Code)
+		}
+	}
+}
+
+func TestRateLimitPolicyMatching(t *testing.T) {
+	h := newPolicyHandler(t, []RateLimitPolicy{
+		{Name: "ip", Pattern: "/*", Key: PolicyKeyIP, Params: ratelimit.Params{Rate: 0.001, Burst: 3}},
+		{Name: "session", Method: http.MethodPost, Pattern: "/user/token/session", Key: PolicyKeyIP, Params: ratelimit.Params{Rate: 0.001, Burst: 1}},
+	})
+	runPolicySteps(t, h, []policyStep{
+		{http.MethodPost, "/user/token/session", "192.0.2.1", "", false, http.StatusNoContent},
+		{http.MethodPost, "/user/token/session", "192.0.2.1", "", false, http.StatusTooManyRequests},
+		{http.MethodGet, "/user/token/session", "192.0.2.1", "", false, http.StatusNoContent},
+		{http.MethodGet, "/user/", "192.0.2.1", "", false, http.StatusTooManyRequests},
+		{http.MethodGet, "/user/", "192.0.2.2", "", false, http.StatusNoContent},
+		{http.MethodGet, "/user/token/session", "192.0.2.2", "", false, http.StatusNoContent},
+		{http.MethodPost, "/user/token/session", "192.0.2.2", "", false, http.StatusNoContent},
+		{http.MethodPost, "/user/token/session", "192.0.2.2", "", true, http.StatusNoContent},
+	})
+}
+
+func TestRateLimitPolicyEmailField(t *testing.T) {
+	h := newPolicyHandler(t, []RateLimitPolicy{
+		{Name: "email", Pattern: "/token", Key: PolicyKeyEmail, Field: "email", Params: ratelimit.Params{Rate: 0.001, Burst: 1}},
+	})
+	runPolicySteps(t, h, []policyStep{
+		{http.MethodPost, "/token", "192.0.2.1", `{"email":"bar@foo.com"}`, false, http.StatusNoContent},
+		{http.MethodPost, "/token", "192.0.2.2", `{"email":"Bar@Foo.com"}`, true, http.StatusTooManyRequests},
+		{http.MethodPost, "/token", "192.0.2.1", `{"email":"baz@foo.com"}`, false, http.StatusNoContent},
+		{http.MethodPost, "/token", "192.0.2.1", `{"name":"bar"}`, false, http.StatusNoContent},
+		{http.MethodPost, "/token", "192.0.2.1", `{"email":1}`, false, http.StatusBadRequest},
+		{http.MethodPost, "/token", "192.0.2.1", `{"email":`, false, http.StatusBadRequest},
+		{http.MethodPost, "/token", "192.0.2.1", `{"email":"` + strings.Repeat("a", 1024) + `@foo.com"}`, false, http.StatusRequestEntityTooLarge},
+	})
+}
+
+func TestRateLimitPolicyUser(t *testing.T) {
+	h := newPolicyHandler(t, []RateLimitPolicy{
+		{Name: "user", Pattern: "/user/*", Key: PolicyKeyUser, Params: ratelimit.Params{Rate: 0.001, Burst: 1}},
+	})
+	runPolicySteps(t, h, []policyStep{
+		{http.MethodGet, "/user/name", "192.0.2.1", "", false, http.StatusNoContent},
+		{http.MethodGet, "/user/name", "192.0.2.1", "", false, http.StatusNoContent},
+		{http.MethodPut, "/user/name", "192.0.2.1", "", false, http.StatusNoContent},
+		{http.MethodPut, "/user/name", "192.0.2.2", "", true, http.StatusTooManyRequests},
+		{http.MethodPut, "/other", "192.0.2.1", "", false, http.StatusNoContent},
+	})
+}
+
+func TestRateLimitPolicyChallenge(t *testing.T) {
+	h := newPolicyHandler(t, []RateLimitPolicy{
+		{Name: "risk", Pattern: "/token", Key: PolicyKeyGlobal, Action: PolicyActionChallenge, Params: ratelimit.Params{Rate: 0.001, Burst: 1}},
+	})
+	runPolicySteps(t, h, []policyStep{
+		{http.MethodPost, "/token", "192.0.2.1", "", false, http.StatusNoContent},
+		{http.MethodPost, "/token", "192.0.2.2", "", false, http.StatusPreconditionRequired},
+		{http.MethodPost, "/token", "192.0.2.3", "", true, http.StatusNoContent},
+	})
+}
+
+func TestWithRateLimitPolicies(t *testing.T) {
+	params := ratelimit.Params{Rate: 1, Burst: 1}
+	tests := []struct {
+		policies []RateLimitPolicy
+		err      error
+	}{
+		{[]RateLimitPolicy{{Name: "a", Pattern: "/a", Key: PolicyKeyIP, Params: params}, {Name: "a", Method: http.MethodGet, Pattern: "/b", Key: PolicyKeyIP, Params: params}}, nil},
+		{[]RateLimitPolicy{{Pattern: "/a", Key: PolicyKeyIP}}, ErrMissingPolicyName},
+		{[]RateLimitPolicy{{Name: "a", Pattern: "a", Key: PolicyKeyIP}}, ErrMissingPolicyPattern},
+		{[]RateLimitPolicy{{Name: "a", Pattern: "/a", Key: PolicyKeyEmail}}, ErrMissingPolicyField},
+		{[]RateLimitPolicy{{Name: "a", Pattern: "/a", Key: "cookie"}}, ErrUnknownPolicyKey},
+		{[]RateLimitPolicy{{Name: "a", Pattern: "/a", Key: PolicyKeyIP, Action: "block"}}, ErrUnknownPolicyAction},
+		{[]RateLimitPolicy{{Name: "a", Pattern: "/a", Key: PolicyKeyIP, Params: ratelimit.Params{Algorithm: "leaky"}}}, ratelimit.ErrUnknownAlgorithm},
+		{[]RateLimitPolicy{{Name: "a", Pattern: "/a", Key: PolicyKeyIP, Params: params}, {Name: "a", Pattern: "/b", Key: PolicyKeyIP}}, ErrConflictingPolicy},
+		{[]RateLimitPolicy{{Name: "a", Pattern: "/a", Key: PolicyKeyIP, Params: params}, {Name: "a", Pattern: "/b", Key: PolicyKeyGlobal, Params: params}}, ErrConflictingPolicy},
+		{[]RateLimitPolicy{{Name: "a", Pattern: "/a", Key: PolicyKeyIP, Params: params}, {Name: "a", Pattern: "/b", Key: PolicyKeyIP, Action: PolicyActionChallenge, Params: params}}, ErrConflictingPolicy},
+	}
+	rl := ratelimit.NewService(time.Minute, time.Minute, 0)
+	defer rl.Close()
+	s := NewService(&Config{})
+	for _, test := range tests {
+		_, err := s.WithRateLimitPolicies(&RateLimitConfig{Service: rl, Policies: test.policies})
+		if !errors.Is(err, test.err) {
+			t.Fatalf("expected error: %v, got: %v", test.err, err)
+		}
+	}
+}
+
+func TestCheckRateLimitPolicies(t *testing.T) {
+	r := chi.NewRouter()
+	r.Route("/user", func(r chi.Router) {
+		r.Get("/", http.NotFound)
+		r.Route("/token", func(r chi.Router) {
+			r.Post("/session", http.NotFound)
+		})
+	})
+	r.With(func(h http.Handler) http.Handler {
+		return userHandler{h}
+	}).Put("/user/name", http.NotFound)
+	r.Method(http.MethodPost, "/user/password-reset", userHandler{http.NotFoundHandler()})
+	r.Delete("/admin/bans/{id}", http.NotFound)
+	tests := []struct {
+		policy RateLimitPolicy
+		err    error
+	}{
+		{RateLimitPolicy{Name: "a", Pattern: "/*"}, nil},
+		{RateLimitPolicy{Name: "a", Method: http.MethodGet, Pattern: "/user/"}, nil},
+		{RateLimitPolicy{Name: "a", Method: "post", Pattern: "/user/token/session"}, nil},
+		{RateLimitPolicy{Name: "a", Pattern: "/user/token/*"}, nil},
+		{RateLimitPolicy{Name: "a", Pattern: "/admin/bans/{banId}"}, nil},
+		{RateLimitPolicy{Name: "a", Method: http.MethodPost, Pattern: "/user/"}, ErrUnmatchedPolicy},
+		{RateLimitPolicy{Name: "a", Pattern: "/user/token/sessions"}, ErrUnmatchedPolicy},
+		{RateLimitPolicy{Name: "a", Pattern: "/token/session"}, ErrUnmatchedPolicy},
+		{RateLimitPolicy{Name: "a", Pattern: "/user/name", Key: PolicyKeyUser}, nil},
+		{RateLimitPolicy{Name: "a", Method: http.MethodPut, Pattern: "/user/*", Key: PolicyKeyUser}, nil},
+		{RateLimitPolicy{Name: "a", Pattern: "/user/password-reset", Key: PolicyKeyUser}, nil},
+		{RateLimitPolicy{Name: "a", Pattern: "/user/*", Key: PolicyKeyUser}, ErrUnresolvedPolicyUser},
+		{RateLimitPolicy{Name: "a", Pattern: "/user/token/session", Key: PolicyKeyUser}, ErrUnresolvedPolicyUser},
+	}
+	for _, test := range tests {
+		if err := CheckRateLimitPolicies(r, []RateLimitPolicy{test.policy}); !errors.Is(err, test.err) {
+			t.Fatalf("expected error: %v for %s %s, got: %v", test.err, test.policy.Method, test.policy.Pattern, err)
+		}
+	}
+}
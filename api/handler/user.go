@@ -2,19 +2,20 @@ package handler
 
 import (
 	"errors"
-	"fmt"
-	"html/template"
-	"net"
 	"net/http"
-	"strconv"
+	netmail "net/mail"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/cyberwlodarczyk/auth/api/argon2id"
+	"github.com/cyberwlodarczyk/auth/api/firewall"
 	"github.com/cyberwlodarczyk/auth/api/jwt"
 	"github.com/cyberwlodarczyk/auth/api/postgres"
-	"github.com/cyberwlodarczyk/auth/api/ratelimit"
 	"github.com/cyberwlodarczyk/auth/api/smtp"
 	"github.com/cyberwlodarczyk/auth/api/validation"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/text/language"
 )
 
 type UserConfirmationToken struct {
@@ -29,24 +30,42 @@ type UserSessionToken struct {
 	Id int64 `json:"id"`
 }
 
+const TokenPlaceholder = "{{token}}"
+
+var ErrInvalidMailURL = errors.New("handler: mail url must be an absolute http(s) url containing " + TokenPlaceholder)
+
 type UserTokenMail struct {
-	Heading string `yaml:"heading"`
-	Action  string `yaml:"action"`
+	Template string `yaml:"template"`
+	URL      string `yaml:"url"`
+}
+
+func (m UserTokenMail) Validate() error {
+	if m.URL == "" {
+		return nil
+	}
+	if !strings.Contains(m.URL, TokenPlaceholder) {
+		return ErrInvalidMailURL
+	}
+	u, err := url.Parse(strings.ReplaceAll(m.URL, TokenPlaceholder, "token"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidMailURL
+	}
+	return nil
+}
+
+func (m UserTokenMail) Link(token string) string {
+	if m.URL == "" {
+		return ""
+	}
+	return strings.ReplaceAll(m.URL, TokenPlaceholder, url.QueryEscape(token))
 }
 
-func (m UserTokenMail) createTmpl() *template.Template {
-	text := strings.ReplaceAll(
-		fmt.Sprintf(`<h1>%s</h1>
-<p>To %s, please use the following token:</p>
-<p><strong>{{.}}</strong></p>
-<p><em>Security Notice:</em> Please do not share this token with anyone else. It is confidential and should be kept private.</p>`,
-			m.Heading,
-			strings.ToLower(m.Action),
-		),
-		"\n",
-		"",
-	)
-	return template.Must(template.New(m.Action).Parse(text))
+type UserTokenMailData struct {
+	Name   string
+	Email  string
+	Token  string
+	Link   string
+	Expiry time.Duration
 }
 
 type UserConfig struct {
@@ -54,13 +73,18 @@ type UserConfig struct {
 	Root               *Service
 	DB                 postgres.UserService
 	Mail               smtp.Service
+	Templates          *smtp.Templates
+	Suppression        smtp.SuppressionList
+	Notifications      postgres.NotificationService
+	NotificationMails  UserNotifications
 	ConfirmationToken  jwt.Service[UserConfirmationToken]
 	SessionToken       jwt.Service[UserSessionToken]
 	SudoToken          jwt.Service[UserSessionToken]
 	PasswordResetToken jwt.Service[UserPasswordResetToken]
 	Password           argon2id.Service
-	NameValidation     validation.Service[string]
-	EmailValidation    validation.Service[string]
+	NameValidation     validation.NameService
+	EmailValidation    validation.EmailService
+	DomainValidation   validation.Service[string]
 	PasswordValidation validation.Service[[]byte]
 }
 
@@ -68,6 +92,7 @@ type UserErrors struct {
 	BadName            string `yaml:"badName"`
 	BadEmail           string `yaml:"badEmail"`
 	BadPassword        string `yaml:"badPassword"`
+	BadLocale          string `yaml:"badLocale"`
 	BadToken           string `yaml:"badToken"`
 	BadSession         string `yaml:"badSession"`
 	MissingSession     string `yaml:"missingSession"`
@@ -81,6 +106,7 @@ type UserService struct {
 	errBadName            error
 	errBadEmail           error
 	errBadPassword        error
+	errBadLocale          error
 	errBadToken           error
 	errBadSession         error
 	errMissingSession     error
@@ -91,13 +117,18 @@ type UserService struct {
 	root                  *Service
 	db                    postgres.UserService
 	mail                  smtp.Service
+	templates             *smtp.Templates
+	suppression           smtp.SuppressionList
+	notifications         postgres.NotificationService
+	notificationMails     UserNotifications
 	confirmationToken     jwt.Service[UserConfirmationToken]
 	sessionToken          jwt.Service[UserSessionToken]
 	sudoToken             jwt.Service[UserSessionToken]
 	passwordResetToken    jwt.Service[UserPasswordResetToken]
 	password              argon2id.Service
-	nameValidation        validation.Service[string]
-	emailValidation       validation.Service[string]
+	nameValidation        validation.NameService
+	emailValidation       validation.EmailService
+	domainValidation      validation.Service[string]
 	passwordValidation    validation.Service[[]byte]
 }
 
@@ -106,6 +137,7 @@ func NewUserService(cfg *UserConfig) *UserService {
 		errBadName:            &operationalError{http.StatusBadRequest, cfg.Errors.BadName},
 		errBadEmail:           &operationalError{http.StatusBadRequest, cfg.Errors.BadEmail},
 		errBadPassword:        &operationalError{http.StatusBadRequest, cfg.Errors.BadPassword},
+		errBadLocale:          &operationalError{http.StatusBadRequest, cfg.Errors.BadLocale},
 		errBadToken:           &operationalError{http.StatusUnauthorized, cfg.Errors.BadToken},
 		errBadSession:         &operationalError{http.StatusUnauthorized, cfg.Errors.BadSession},
 		errMissingSession:     &operationalError{http.StatusUnauthorized, cfg.Errors.MissingSession},
@@ -116,6 +148,10 @@ func NewUserService(cfg *UserConfig) *UserService {
 		root:                  cfg.Root,
 		db:                    cfg.DB,
 		mail:                  cfg.Mail,
+		templates:             cfg.Templates,
+		suppression:           cfg.Suppression,
+		notifications:         cfg.Notifications,
+		notificationMails:     cfg.NotificationMails,
 		confirmationToken:     cfg.ConfirmationToken,
 		sessionToken:          cfg.SessionToken,
 		sudoToken:             cfg.SudoToken,
@@ -123,6 +159,7 @@ func NewUserService(cfg *UserConfig) *UserService {
 		password:              cfg.Password,
 		nameValidation:        cfg.NameValidation,
 		emailValidation:       cfg.EmailValidation,
+		domainValidation:      cfg.DomainValidation,
 		passwordValidation:    cfg.PasswordValidation,
 	}
 }
@@ -145,8 +182,29 @@ func (s *UserService) isNotFound(err error) error {
 	return err
 }
 
-func (s *UserService) WithSession(svc jwt.Service[UserSessionToken], limiter ratelimit.Limiter) func(http.Handler) http.Handler {
-	return s.root.createMiddleware(func(h http.Handler, w http.ResponseWriter, r *http.Request) error {
+func (s *UserService) isUnavailable(err error) error {
+	if errors.Is(err, argon2id.ErrQueueTimeout) {
+		return s.root.errUnavailable
+	}
+	return err
+}
+
+func (s *UserService) sendTokenMail(r *http.Request, mail UserTokenMail, to netmail.Address, locale string, token string, age time.Duration) error {
+	tmpl, err := s.templates.Get(mail.Template, locale, r.Header.Get("Accept-Language"))
+	if err != nil {
+		return err
+	}
+	return s.mail.Send(r.Context(), to, tmpl, UserTokenMailData{
+		Name:   to.Name,
+		Email:  to.Address,
+		Token:  token,
+		Link:   mail.Link(token),
+		Expiry: age,
+	})
+}
+
+func (s *UserService) WithSession(svc jwt.Service[UserSessionToken]) func(http.Handler) http.Handler {
+	m := s.root.createMiddleware(func(h http.Handler, w http.ResponseWriter, r *http.Request) error {
 		header := strings.Split(r.Header.Get("Authorization"), " ")
 		if len(header) != 2 || header[0] != "Bearer" {
 			return s.errMissingSession
@@ -158,43 +216,48 @@ func (s *UserService) WithSession(svc jwt.Service[UserSessionToken], limiter rat
 			}
 			return err
 		}
-		if !limiter.Allow(strconv.FormatInt(token.Id, 16)) {
-			return s.root.errTooManyRequests
+		r = setUserID(r, token.Id)
+		if err = s.root.applyUserPolicies(w, r); err != nil {
+			return err
 		}
-		h.ServeHTTP(w, setUserID(r, token.Id))
+		h.ServeHTTP(w, r)
 		return nil
 	})
+	return func(h http.Handler) http.Handler {
+		return userHandler{m(h)}
+	}
 }
 
-func (s *UserService) CreateConfirmationToken(mail UserTokenMail, limiter ratelimit.Limiter) http.HandlerFunc {
+func (s *UserService) CreateConfirmationToken(mail UserTokenMail) http.HandlerFunc {
 	type body struct {
-		Email string `json:"email"`
+		Email  string `json:"email"`
+		Locale string `json:"locale"`
 	}
-	tmpl := mail.createTmpl()
 	return s.root.createHandler(func(w http.ResponseWriter, r *http.Request) (res response, err error) {
 		var body body
 		if err = s.decodeJSONBody(r, &body); err != nil {
 			return
 		}
-		if !s.emailValidation.Check(body.Email) {
-			err = s.errBadEmail
+		email := s.emailValidation.Normalize(body.Email)
+		if err = validate(s.errBadEmail, "email", s.emailValidation.Check(email.Address)); err != nil {
 			return
 		}
-		if !limiter.Allow(body.Email) {
-			err = s.root.errTooManyRequests
+		if err = validate(s.errBadEmail, "email", s.domainValidation.Check(email.Address)); err != nil {
 			return
 		}
-		token, err := s.confirmationToken.Sign(UserConfirmationToken(body))
+		token, err := s.confirmationToken.Sign(UserConfirmationToken{email.Address})
 		if err != nil {
 			return
 		}
-		s.mail.Send(body.Email, tmpl, token)
+		if err = s.sendTokenMail(r, mail, netmail.Address{Address: email.Address}, body.Locale, token, s.confirmationToken.Age()); err != nil {
+			return
+		}
 		res = response{http.StatusNoContent, nil}
 		return
 	})
 }
 
-func (s *UserService) CreateSessionToken(ipLimiter ratelimit.Limiter, emailLimiter ratelimit.Limiter) http.HandlerFunc {
+func (s *UserService) CreateSessionToken() http.HandlerFunc {
 	type body struct {
 		Email    string `json:"email"`
 		Password []byte `json:"password"`
@@ -208,30 +271,25 @@ func (s *UserService) CreateSessionToken(ipLimiter ratelimit.Limiter, emailLimit
 		if err = s.decodeJSONBody(r, &body); err != nil {
 			return
 		}
-		if !s.emailValidation.Check(body.Email) {
-			err = s.errBadEmail
+		email := s.emailValidation.Normalize(body.Email)
+		if err = validate(s.errBadEmail, "email", s.emailValidation.Check(email.Address)); err != nil {
 			return
 		}
-		ip, _, err := net.SplitHostPort(r.RemoteAddr)
-		if err != nil {
-			return
-		}
-		if !ipLimiter.Allow(ip) || !emailLimiter.Allow(body.Email) {
-			err = s.root.errTooManyRequests
-			return
-		}
-		user, err := s.db.GetByEmail(r.Context(), body.Email)
+		user, err := s.db.GetByEmail(r.Context(), email.Canonical)
 		if err != nil {
 			if errors.Is(err, postgres.ErrNotFound) {
+				s.root.strike(r, firewall.StrikeFailedLogin)
 				err = s.errInvalidCredentials
 			}
 			return
 		}
-		match, _, err := s.password.Compare(body.Password, user.Password)
+		match, _, err := s.password.Compare(r.Context(), body.Password, user.Password)
 		if err != nil {
+			err = s.isUnavailable(err)
 			return
 		}
 		if !match {
+			s.root.strike(r, firewall.StrikeFailedLogin)
 			err = s.errInvalidCredentials
 			return
 		}
@@ -239,30 +297,26 @@ func (s *UserService) CreateSessionToken(ipLimiter ratelimit.Limiter, emailLimit
 		if err != nil {
 			return
 		}
+		s.notifyNewDevice(w, r, user)
 		res = response{http.StatusCreated, payload{token, user}}
 		return
 	})
 }
 
-func (s *UserService) CreatePasswordResetToken(mail UserTokenMail, limiter ratelimit.Limiter) http.HandlerFunc {
+func (s *UserService) CreatePasswordResetToken(mail UserTokenMail) http.HandlerFunc {
 	type body struct {
 		Email string `json:"email"`
 	}
-	tmpl := mail.createTmpl()
 	return s.root.createHandler(func(w http.ResponseWriter, r *http.Request) (res response, err error) {
 		var body body
 		if err = s.decodeJSONBody(r, &body); err != nil {
 			return
 		}
-		if !s.emailValidation.Check(body.Email) {
-			err = s.errBadEmail
-			return
-		}
-		if !limiter.Allow(body.Email) {
-			err = s.root.errTooManyRequests
+		email := s.emailValidation.Normalize(body.Email)
+		if err = validate(s.errBadEmail, "email", s.emailValidation.Check(email.Address)); err != nil {
 			return
 		}
-		user, err := s.db.GetByEmail(r.Context(), body.Email)
+		user, err := s.db.GetByEmail(r.Context(), email.Canonical)
 		if err != nil {
 			if errors.Is(err, postgres.ErrNotFound) {
 				err = nil
@@ -274,17 +328,18 @@ func (s *UserService) CreatePasswordResetToken(mail UserTokenMail, limiter ratel
 		if err != nil {
 			return
 		}
-		s.mail.Send(body.Email, tmpl, token)
+		if err = s.sendTokenMail(r, mail, netmail.Address{Name: user.Name, Address: user.Email}, user.Locale, token, s.passwordResetToken.Age()); err != nil {
+			return
+		}
 		res = response{http.StatusNoContent, nil}
 		return
 	})
 }
 
-func (s *UserService) CreateSudoToken(mail UserTokenMail, limiter ratelimit.Limiter) http.HandlerFunc {
+func (s *UserService) CreateSudoToken(mail UserTokenMail) http.HandlerFunc {
 	type body struct {
 		Password []byte `json:"password"`
 	}
-	tmpl := mail.createTmpl()
 	return s.root.createHandler(func(w http.ResponseWriter, r *http.Request) (res response, err error) {
 		var body body
 		if err = s.decodeJSONBody(r, &body); err != nil {
@@ -296,50 +351,58 @@ func (s *UserService) CreateSudoToken(mail UserTokenMail, limiter ratelimit.Limi
 			err = s.isNotFound(err)
 			return
 		}
-		match, _, err := s.password.Compare(body.Password, user.Password)
+		match, _, err := s.password.Compare(r.Context(), body.Password, user.Password)
 		if err != nil {
+			err = s.isUnavailable(err)
 			return
 		}
 		if !match {
 			err = s.errInvalidPassword
 			return
 		}
-		if !limiter.Allow(strconv.FormatInt(id, 16)) {
-			err = s.root.errTooManyRequests
-			return
-		}
 		token, err := s.sudoToken.Sign(UserSessionToken{id})
 		if err != nil {
 			return
 		}
-		s.mail.Send(user.Email, tmpl, token)
+		if err = s.sendTokenMail(r, mail, netmail.Address{Name: user.Name, Address: user.Email}, user.Locale, token, s.sudoToken.Age()); err != nil {
+			return
+		}
 		res = response{http.StatusCreated, nil}
 		return
 	})
 }
 
 func (s *UserService) Get() http.HandlerFunc {
+	type user struct {
+		postgres.User
+		Undeliverable bool `json:"undeliverable"`
+	}
 	type payload struct {
-		User postgres.User `json:"user"`
+		User user `json:"user"`
 	}
 	return s.root.createHandler(func(w http.ResponseWriter, r *http.Request) (res response, err error) {
-		user, err := s.db.GetById(r.Context(), getUserID(r))
+		u, err := s.db.GetById(r.Context(), getUserID(r))
 		if err != nil {
 			err = s.isNotFound(err)
 			return
 		}
+		undeliverable, err := s.suppression.IsSuppressed(r.Context(), u.Email)
+		if err != nil {
+			return
+		}
 		res = response{
 			http.StatusOK,
-			payload{user},
+			payload{user{u, undeliverable}},
 		}
 		return
 	})
 }
 
-func (s *UserService) Create(limiter ratelimit.Limiter) http.HandlerFunc {
+func (s *UserService) Create() http.HandlerFunc {
 	type body struct {
 		Token    string `json:"token"`
 		Name     string `json:"name"`
+		Locale   string `json:"locale"`
 		Password []byte `json:"password"`
 	}
 	type payload struct {
@@ -356,30 +419,25 @@ func (s *UserService) Create(limiter ratelimit.Limiter) http.HandlerFunc {
 			err = s.isNotFound(err)
 			return
 		}
-		if !s.nameValidation.Check(body.Name) {
-			err = s.errBadName
-			return
-		}
-		if !s.passwordValidation.Check(body.Password) {
-			err = s.errBadPassword
+		name := s.nameValidation.Normalize(body.Name)
+		if err = validate(s.errBadName, "name", s.nameValidation.Check(name)); err != nil {
 			return
 		}
-		ip, _, err := net.SplitHostPort(r.RemoteAddr)
-		if err != nil {
-			return
-		}
-		if !limiter.Allow(ip) {
-			err = s.root.errTooManyRequests
+		if err = validate(s.errBadPassword, "password", s.passwordValidation.Check(body.Password)); err != nil {
 			return
 		}
-		hash, err := s.password.Hash(body.Password)
+		hash, err := s.password.Hash(r.Context(), body.Password)
 		if err != nil {
+			err = s.isUnavailable(err)
 			return
 		}
+		email := s.emailValidation.Normalize(token.Email)
 		user, err := s.db.Create(r.Context(), postgres.CreateUserOpts{
-			Email:    token.Email,
-			Name:     body.Name,
-			Password: hash,
+			Email:          email.Address,
+			CanonicalEmail: email.Canonical,
+			Name:           name,
+			Locale:         s.templates.Locale(body.Locale, r.Header.Get("Accept-Language")),
+			Password:       hash,
 		})
 		if errors.Is(err, postgres.ErrAlreadyExists) {
 			err = s.errAlreadyExists
@@ -392,6 +450,9 @@ func (s *UserService) Create(limiter ratelimit.Limiter) http.HandlerFunc {
 		if err != nil {
 			return
 		}
+		if _, err := s.touchDevice(w, r, user.Id); err != nil {
+			logrus.WithField("userId", user.Id).Error(err)
+		}
 		res = response{http.StatusCreated, payload{session, user}}
 		return
 	})
@@ -411,11 +472,26 @@ func (s *UserService) EditEmail() http.HandlerFunc {
 			err = s.isBadToken(err)
 			return
 		}
-		err = s.db.EditEmail(r.Context(), getUserID(r), token.Email)
+		email := s.emailValidation.Normalize(token.Email)
+		if err = validate(s.errBadEmail, "email", s.domainValidation.Check(email.Address)); err != nil {
+			return
+		}
+		user, err := s.db.GetById(r.Context(), getUserID(r))
+		if err != nil {
+			err = s.isNotFound(err)
+			return
+		}
+		err = s.db.EditEmail(r.Context(), user.Id, email.Address, email.Canonical)
+		if errors.Is(err, postgres.ErrAlreadyExists) {
+			err = s.errAlreadyExists
+			return
+		}
 		if err != nil {
 			err = s.isNotFound(err)
 			return
 		}
+		s.notify(r, s.notificationMails.SudoUsed, user, "")
+		s.notify(r, s.notificationMails.EmailChanged, user, email.Address)
 		res = response{http.StatusNoContent, nil}
 		return
 	})
@@ -430,11 +506,34 @@ func (s *UserService) EditName() http.HandlerFunc {
 		if err = s.decodeJSONBody(r, &body); err != nil {
 			return
 		}
-		if !s.nameValidation.Check(body.Name) {
-			err = s.errBadName
+		name := s.nameValidation.Normalize(body.Name)
+		if err = validate(s.errBadName, "name", s.nameValidation.Check(name)); err != nil {
+			return
+		}
+		err = s.db.EditName(r.Context(), getUserID(r), name)
+		if err != nil {
+			err = s.isNotFound(err)
+			return
+		}
+		res = response{http.StatusNoContent, nil}
+		return
+	})
+}
+
+func (s *UserService) EditLocale() http.HandlerFunc {
+	type body struct {
+		Locale string `json:"locale"`
+	}
+	return s.root.createHandler(func(w http.ResponseWriter, r *http.Request) (res response, err error) {
+		var body body
+		if err = s.decodeJSONBody(r, &body); err != nil {
+			return
+		}
+		if _, err = language.Parse(body.Locale); err != nil {
+			err = s.errBadLocale
 			return
 		}
-		err = s.db.EditName(r.Context(), getUserID(r), body.Name)
+		err = s.db.EditLocale(r.Context(), getUserID(r), s.templates.Locale(body.Locale))
 		if err != nil {
 			err = s.isNotFound(err)
 			return
@@ -454,8 +553,7 @@ func (s *UserService) EditPassword() http.HandlerFunc {
 		if err = s.decodeJSONBody(r, &body); err != nil {
 			return
 		}
-		if !s.passwordValidation.Check(body.NewPassword) {
-			err = s.errBadPassword
+		if err = validate(s.errBadPassword, "newPassword", s.passwordValidation.Check(body.NewPassword)); err != nil {
 			return
 		}
 		id := getUserID(r)
@@ -464,28 +562,31 @@ func (s *UserService) EditPassword() http.HandlerFunc {
 			err = s.isNotFound(err)
 			return
 		}
-		match, _, err := s.password.Compare(body.Password, user.Password)
+		match, _, err := s.password.Compare(r.Context(), body.Password, user.Password)
 		if err != nil {
+			err = s.isUnavailable(err)
 			return
 		}
 		if !match {
 			err = s.errInvalidPassword
 			return
 		}
-		hash, err := s.password.Hash(body.NewPassword)
+		hash, err := s.password.Hash(r.Context(), body.NewPassword)
 		if err != nil {
+			err = s.isUnavailable(err)
 			return
 		}
 		if err = s.db.EditPassword(r.Context(), id, hash); err != nil {
 			err = s.isNotFound(err)
 			return
 		}
+		s.notify(r, s.notificationMails.PasswordChanged, user, "")
 		res = response{http.StatusNoContent, nil}
 		return
 	})
 }
 
-func (s *UserService) ResetPassword(limiter ratelimit.Limiter) http.HandlerFunc {
+func (s *UserService) ResetPassword() http.Handler {
 	type body struct {
 		Token    string `json:"token"`
 		Password []byte `json:"password"`
@@ -493,13 +594,12 @@ func (s *UserService) ResetPassword(limiter ratelimit.Limiter) http.HandlerFunc
 	type payload struct {
 		Session string `json:"session"`
 	}
-	return s.root.createHandler(func(w http.ResponseWriter, r *http.Request) (res response, err error) {
+	return userHandler{s.root.createHandler(func(w http.ResponseWriter, r *http.Request) (res response, err error) {
 		var body body
 		if err = s.decodeJSONBody(r, &body); err != nil {
 			return
 		}
-		if !s.passwordValidation.Check(body.Password) {
-			err = s.errBadPassword
+		if err = validate(s.errBadPassword, "password", s.passwordValidation.Check(body.Password)); err != nil {
 			return
 		}
 		token, err := s.passwordResetToken.Verify(body.Token)
@@ -507,12 +607,18 @@ func (s *UserService) ResetPassword(limiter ratelimit.Limiter) http.HandlerFunc
 			err = s.isBadToken(err)
 			return
 		}
-		if !limiter.Allow(strconv.FormatInt(token.Id, 16)) {
-			err = s.root.errTooManyRequests
+		r = setUserID(r, token.Id)
+		if err = s.root.applyUserPolicies(w, r); err != nil {
+			return
+		}
+		user, err := s.db.GetById(r.Context(), token.Id)
+		if err != nil {
+			err = s.isNotFound(err)
 			return
 		}
-		hash, err := s.password.Hash(body.Password)
+		hash, err := s.password.Hash(r.Context(), body.Password)
 		if err != nil {
+			err = s.isUnavailable(err)
 			return
 		}
 		err = s.db.EditPassword(r.Context(), token.Id, hash)
@@ -520,21 +626,29 @@ func (s *UserService) ResetPassword(limiter ratelimit.Limiter) http.HandlerFunc
 			err = s.isNotFound(err)
 			return
 		}
+		s.notify(r, s.notificationMails.PasswordChanged, user, "")
 		session, err := s.sessionToken.Sign(UserSessionToken(token))
 		if err != nil {
 			return
 		}
 		res = response{http.StatusCreated, payload{session}}
 		return
-	})
+	})}
 }
 
 func (s *UserService) Delete() http.HandlerFunc {
 	return s.root.createHandler(func(w http.ResponseWriter, r *http.Request) (res response, err error) {
-		if err = s.db.Delete(r.Context(), getUserID(r)); err != nil {
+		user, err := s.db.GetById(r.Context(), getUserID(r))
+		if err != nil {
+			err = s.isNotFound(err)
+			return
+		}
+		if err = s.db.Delete(r.Context(), user.Id); err != nil {
 			err = s.isNotFound(err)
 			return
 		}
+		s.notify(r, s.notificationMails.SudoUsed, user, "")
+		s.notify(r, s.notificationMails.AccountDeleted, user, "")
 		res = response{http.StatusNoContent, nil}
 		return
 	})
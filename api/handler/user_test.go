@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cyberwlodarczyk/auth/api/argon2id"
+	"github.com/cyberwlodarczyk/auth/api/jwt"
+	"github.com/cyberwlodarczyk/auth/api/postgres"
+	"github.com/cyberwlodarczyk/auth/api/smtp"
+	"github.com/cyberwlodarczyk/auth/api/validation"
+	"github.com/go-chi/chi/v5"
+)
+
+type userDB struct {
+	users []postgres.User
+	mutex sync.Mutex
+}
+
+func (db *userDB) find(match func(postgres.User) bool) (postgres.User, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	for _, user := range db.users {
+		if match(user) {
+			return user, nil
+		}
+	}
+	return postgres.User{}, postgres.ErrNotFound
+}
+
+func (db *userDB) GetById(ctx context.Context, id int64) (postgres.User, error) {
+	return db.find(func(user postgres.User) bool { return user.Id == id })
+}
+
+func (db *userDB) GetByEmail(ctx context.Context, canonicalEmail string) (postgres.User, error) {
+	return db.find(func(user postgres.User) bool { return user.CanonicalEmail == canonicalEmail })
+}
+
+func (db *userDB) Create(ctx context.Context, opts postgres.CreateUserOpts) (postgres.User, error) {
+	if _, err := db.GetByEmail(ctx, opts.CanonicalEmail); err == nil {
+		return postgres.User{}, postgres.ErrAlreadyExists
+	}
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	user := postgres.User{
+		Id:             int64(len(db.users) + 1),
+		Email:          opts.Email,
+		CanonicalEmail: opts.CanonicalEmail,
+		Name:           opts.Name,
+		Locale:         opts.Locale,
+		Password:       opts.Password,
+		CreatedAt:      time.Now(),
+	}
+	db.users = append(db.users, user)
+	return user, nil
+}
+
+func (db *userDB) EditEmail(ctx context.Context, id int64, email, canonicalEmail string) error {
+	return nil
+}
+
+func (db *userDB) EditName(ctx context.Context, id int64, name string) error {
+	return nil
+}
+
+func (db *userDB) EditLocale(ctx context.Context, id int64, locale string) error {
+	return nil
+}
+
+func (db *userDB) EditPassword(ctx context.Context, id int64, password string) error {
+	return nil
+}
+
+func (db *userDB) Delete(ctx context.Context, id int64) error {
+	return nil
+}
+
+type notificationDB struct{}
+
+func (notificationDB) GetPreferences(ctx context.Context, id int64) (postgres.NotificationPreferences, error) {
+	return postgres.NotificationPreferences{}, nil
+}
+
+func (notificationDB) EditPreferences(ctx context.Context, id int64, prefs postgres.NotificationPreferences) error {
+	return nil
+}
+
+func (notificationDB) TouchDevice(ctx context.Context, id int64, fingerprint string) (bool, error) {
+	return true, nil
+}
+
+var tokenPattern = regexp.MustCompile(`[\w-]+\.[\w-]+\.[\w-]+`)
+
+func TestUserSignUp(t *testing.T) {
+	templates, err := smtp.LoadTemplates(&smtp.TemplatesConfig{Dir: "../templates", DefaultLocale: "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mailbox, err := smtp.NewMemoryService(&smtp.Config{From: "noreply@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	password, err := argon2id.NewService(&argon2id.Config{
+		Params:       argon2id.Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 16},
+		MemoryBudget: 64,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, err := validation.NewNameService(&validation.NameConfig{Min: 1, Max: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	email, err := validation.NewEmailService(&validation.EmailConfig{Pattern: "^.+@.+$"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	domain, err := validation.NewDomainService(&validation.DomainConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer domain.Close()
+	passwordValidation, err := validation.NewPasswordService(&validation.PasswordConfig{MinLength: 8, MaxLength: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := jwt.Secret("secret")
+	sessionToken := jwt.NewService[UserSessionToken](jwt.Config{Secret: secret, Age: time.Hour})
+	user := NewUserService(&UserConfig{
+		Root:               NewService(&Config{}),
+		DB:                 &userDB{},
+		Mail:               mailbox,
+		Templates:          templates,
+		Suppression:        suppressionList{},
+		Notifications:      notificationDB{},
+		ConfirmationToken:  jwt.NewService[UserConfirmationToken](jwt.Config{Secret: secret, Age: time.Minute}),
+		SessionToken:       sessionToken,
+		SudoToken:          jwt.NewService[UserSessionToken](jwt.Config{Secret: secret, Age: time.Minute}),
+		PasswordResetToken: jwt.NewService[UserPasswordResetToken](jwt.Config{Secret: secret, Age: time.Minute}),
+		Password:           password,
+		NameValidation:     name,
+		EmailValidation:    email,
+		DomainValidation:   domain,
+		PasswordValidation: passwordValidation,
+	})
+	r := chi.NewRouter()
+	r.Post("/token/confirmation", user.CreateConfirmationToken(UserTokenMail{Template: "confirmation"}))
+	r.Post("/", user.Create())
+	r.Post("/token/session", user.CreateSessionToken())
+	r.With(user.WithSession(sessionToken)).Get("/", user.Get())
+	send := func(method, path, session string, body any, status int, v any) {
+		t.Helper()
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(method, path, bytes.NewReader(b))
+		req.Header.Set("Content-Type", "application/json")
+		if session != "" {
+			req.Header.Set("Authorization", "Bearer "+session)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != status {
+			t.Fatalf("expected status: %d for %s %s, got: %d %s", status, method, path, w.Code, w.Body)
+		}
+		if v != nil {
+			if err = json.Unmarshal(w.Body.Bytes(), v); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	send(http.MethodPost, "/token/confirmation", "", map[string]string{"email": "bob@example.com"}, http.StatusNoContent, nil)
+	message, ok := mailbox.Last("bob@example.com")
+	if !ok {
+		t.Fatal("expected confirmation mail to be sent")
+	}
+	confirmation := tokenPattern.FindString(message.Text)
+	if confirmation == "" {
+		t.Fatalf("expected confirmation token in: %q", message.Text)
+	}
+	body := map[string]any{"token": confirmation, "name": "bob", "password": []byte("pa$$word123")}
+	var created struct {
+		Session string        `json:"session"`
+		User    postgres.User `json:"user"`
+	}
+	send(http.MethodPost, "/", "", body, http.StatusCreated, &created)
+	send(http.MethodPost, "/", "", body, http.StatusConflict, nil)
+	var session struct {
+		Token string `json:"token"`
+	}
+	send(http.MethodPost, "/token/session", "", map[string]any{"email": "bob@example.com", "password": []byte("pa$$word123")}, http.StatusCreated, &session)
+	send(http.MethodPost, "/token/session", "", map[string]any{"email": "bob@example.com", "password": []byte("wrong")}, http.StatusUnauthorized, nil)
+	var got struct {
+		User postgres.User `json:"user"`
+	}
+	send(http.MethodGet, "/", session.Token, nil, http.StatusOK, &got)
+	if got.User.Id != created.User.Id || got.User.Email != "bob@example.com" {
+		t.Fatalf("expected user: %+v, got: %+v", created.User, got.User)
+	}
+}
@@ -44,6 +44,7 @@ type Config struct {
 type Service[T any] interface {
 	Sign(T) (string, error)
 	Verify(string) (T, error)
+	Age() time.Duration
 }
 
 func NewService[T any](cfg Config) Service[T] {
@@ -55,6 +56,10 @@ type service[T any] struct {
 	age time.Duration
 }
 
+func (s *service[T]) Age() time.Duration {
+	return s.age
+}
+
 func (s *service[T]) Sign(data T) (t string, err error) {
 	t, err = jwt.NewWithClaims(
 		jwt.SigningMethodHS256,
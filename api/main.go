@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"expvar"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
@@ -12,7 +14,9 @@ import (
 	"time"
 
 	"github.com/cyberwlodarczyk/auth/api/argon2id"
+	"github.com/cyberwlodarczyk/auth/api/challenge"
 	"github.com/cyberwlodarczyk/auth/api/config"
+	"github.com/cyberwlodarczyk/auth/api/firewall"
 	"github.com/cyberwlodarczyk/auth/api/handler"
 	"github.com/cyberwlodarczyk/auth/api/jwt"
 	"github.com/cyberwlodarczyk/auth/api/postgres"
@@ -24,22 +28,160 @@ import (
 )
 
 func run(cfg *config.Config) error {
+	nameValidation, err := validation.NewNameService(&cfg.Validation.User.Name)
+	if err != nil {
+		return fmt.Errorf("validation.user.name: %w", err)
+	}
+	emailValidation, err := validation.NewEmailService(&cfg.Validation.User.Email)
+	if err != nil {
+		return fmt.Errorf("validation.user.email: %w", err)
+	}
+	passwordValidation, err := validation.NewPasswordService(&cfg.Validation.User.Password)
+	if err != nil {
+		return fmt.Errorf("validation.user.password: %w", err)
+	}
+	templates, err := smtp.LoadTemplates(&cfg.Mail.Templates)
+	if err != nil {
+		return fmt.Errorf("mail.templates: %w", err)
+	}
+	for name, mail := range map[string]handler.UserTokenMail{
+		"confirmation":  cfg.Mail.User.Confirmation,
+		"passwordReset": cfg.Mail.User.PasswordReset,
+		"sudo":          cfg.Mail.User.Sudo,
+	} {
+		if !templates.Has(mail.Template) {
+			return fmt.Errorf("mail.user.%s: %w: %s", name, smtp.ErrTemplateNotFound, mail.Template)
+		}
+		if err = mail.Validate(); err != nil {
+			return fmt.Errorf("mail.user.%s: %w", name, err)
+		}
+	}
+	for name, mail := range map[string]handler.UserNotificationMail{
+		"passwordChanged": cfg.Mail.User.Notifications.PasswordChanged,
+		"emailChanged":    cfg.Mail.User.Notifications.EmailChanged,
+		"accountDeleted":  cfg.Mail.User.Notifications.AccountDeleted,
+		"newLogin":        cfg.Mail.User.Notifications.NewLogin,
+		"sudoUsed":        cfg.Mail.User.Notifications.SudoUsed,
+	} {
+		if !templates.Has(mail.Template) {
+			return fmt.Errorf("mail.user.notifications.%s: %w: %s", name, smtp.ErrTemplateNotFound, mail.Template)
+		}
+	}
+	errorWriter := logrus.StandardLogger().WriterLevel(logrus.ErrorLevel)
+	defer errorWriter.Close()
+	cfg.Validation.User.Domain.ErrorLog = log.New(errorWriter, "", 0)
+	domainValidation, err := validation.NewDomainService(&cfg.Validation.User.Domain)
+	if err != nil {
+		return fmt.Errorf("validation.user.domain: %w", err)
+	}
+	defer domainValidation.Close()
 	db, err := postgres.NewService(context.Background(), cfg.Postgres)
 	if err != nil {
 		return err
 	}
 	defer db.Close()
-	userDB, err := postgres.NewUserService(context.Background(), db)
+	canonicalEmail := func(email string) string {
+		return emailValidation.Normalize(email).Canonical
+	}
+	userDB, err := postgres.NewUserService(context.Background(), db, canonicalEmail)
 	if err != nil {
 		return err
 	}
-	errorWriter := logrus.StandardLogger().WriterLevel(logrus.ErrorLevel)
-	defer errorWriter.Close()
+	mailDB, err := postgres.NewMailService(context.Background(), db)
+	if err != nil {
+		return err
+	}
+	suppressionDB, err := postgres.NewSuppressionService(context.Background(), db, canonicalEmail)
+	if err != nil {
+		return err
+	}
+	notificationDB, err := postgres.NewNotificationService(context.Background(), db)
+	if err != nil {
+		return err
+	}
+	cfg.SMTP.DB = mailDB
+	cfg.SMTP.Suppression = suppressionDB
 	cfg.SMTP.ErrorLog = log.New(errorWriter, "", 0)
 	cfg.SMTP.TLSConfig = &tls.Config{ServerName: cfg.SMTP.Host}
-	mail := smtp.NewService(&cfg.SMTP)
+	mail, err := smtp.NewBackend(&cfg.SMTP)
+	if err != nil {
+		return err
+	}
 	defer mail.Close()
-	root := handler.NewService(&handler.Config{Errors: cfg.Errors.Root})
+	trustedProxies, err := handler.ParseTrustedProxies(cfg.HTTP.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("http.trustedProxies: %w", err)
+	}
+	forwardedHeader, err := handler.ParseForwardedHeader(cfg.HTTP.ForwardedHeader)
+	if err != nil {
+		return fmt.Errorf("http.forwardedHeader: %w", err)
+	}
+	banDB, err := postgres.NewBanService(context.Background(), db)
+	if err != nil {
+		return err
+	}
+	cfg.Firewall.DB = banDB
+	cfg.Firewall.ErrorLog = log.New(errorWriter, "", 0)
+	fw, err := firewall.NewService(&cfg.Firewall)
+	if err != nil {
+		return fmt.Errorf("firewall: %w", err)
+	}
+	defer fw.Close()
+	var rl ratelimit.Service
+	switch cfg.RateLimit.Backend {
+	case ratelimit.BackendMemory:
+		rl = ratelimit.NewService(
+			cfg.RateLimit.CleanupInterval,
+			cfg.RateLimit.IdleTimeout,
+			cfg.RateLimit.MaxKeys,
+		)
+	case ratelimit.BackendRedis:
+		rl, err = ratelimit.NewRedisService(context.Background(), &cfg.RateLimit.Redis)
+	case ratelimit.BackendPostgres:
+		rl, err = postgres.NewRateLimitService(
+			context.Background(),
+			db,
+			cfg.RateLimit.CleanupInterval,
+			log.New(errorWriter, "", 0),
+		)
+	default:
+		err = fmt.Errorf("%w: %s", ratelimit.ErrUnknownBackend, cfg.RateLimit.Backend)
+	}
+	if err != nil {
+		return fmt.Errorf("rateLimit: %w", err)
+	}
+	defer rl.Close()
+	expvar.Publish("ratelimit", expvar.Func(func() any {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		stats, err := rl.Stats(ctx)
+		if err != nil {
+			return err.Error()
+		}
+		return stats
+	}))
+	if cfg.RateLimit.Backend != ratelimit.BackendMemory {
+		cfg.Challenge.PoW.RateLimit = rl
+	}
+	verifier, err := challenge.NewVerifier(&cfg.Challenge)
+	if err != nil {
+		return fmt.Errorf("challenge: %w", err)
+	}
+	defer verifier.Close()
+	password, err := argon2id.NewService(&cfg.Argon2id)
+	if err != nil {
+		return fmt.Errorf("argon2id: %w", err)
+	}
+	expvar.Publish("argon2id", expvar.Func(func() any {
+		return password.Stats()
+	}))
+	root := handler.NewService(&handler.Config{
+		Errors:          cfg.Errors.Root,
+		TrustedProxies:  trustedProxies,
+		ForwardedHeader: forwardedHeader,
+		Firewall:        fw,
+		Challenge:       verifier,
+	})
 	userSessionToken := jwt.NewService[handler.UserSessionToken](cfg.JWT.User.Session)
 	userSudoToken := jwt.NewService[handler.UserSessionToken](cfg.JWT.User.Sudo)
 	user := handler.NewUserService(&handler.UserConfig{
@@ -47,36 +189,82 @@ func run(cfg *config.Config) error {
 		Root:               root,
 		DB:                 userDB,
 		Mail:               mail,
+		Templates:          templates,
+		Suppression:        suppressionDB,
+		Notifications:      notificationDB,
+		NotificationMails:  cfg.Mail.User.Notifications,
 		ConfirmationToken:  jwt.NewService[handler.UserConfirmationToken](cfg.JWT.User.Confirmation),
 		SessionToken:       userSessionToken,
 		SudoToken:          userSudoToken,
 		PasswordResetToken: jwt.NewService[handler.UserPasswordResetToken](cfg.JWT.User.PasswordReset),
-		Password:           argon2id.NewService(argon2id.DefaultParams),
-		NameValidation:     validation.NewMinMaxService(cfg.Validation.User.Name),
-		EmailValidation:    validation.NewEmailService(validation.DefaultEmailPattern),
-		PasswordValidation: validation.NewPasswordService(validation.DefaultPasswordConfig),
+		Password:           password,
+		NameValidation:     nameValidation,
+		EmailValidation:    emailValidation,
+		DomainValidation:   domainValidation,
+		PasswordValidation: passwordValidation,
 	})
-	rl := ratelimit.NewService(
-		cfg.RateLimit.CleanupInterval,
-		cfg.RateLimit.IdleTimeout,
-	)
-	defer rl.Close()
+	mailHandler := handler.NewMailService(&handler.MailConfig{
+		Errors:        cfg.Errors.Mail,
+		Root:          root,
+		Suppression:   suppressionDB,
+		WebhookSecret: cfg.Mail.Webhook.Secret,
+	})
+	outbox, _ := mail.(*smtp.MemoryService)
+	admin := handler.NewAdminService(&handler.AdminConfig{
+		Errors:      cfg.Errors.Admin,
+		Root:        root,
+		Firewall:    fw,
+		Suppression: suppressionDB,
+		Outbox:      outbox,
+		Secret:      cfg.Admin.Secret,
+	})
+	policies, err := root.WithRateLimitPolicies(&handler.RateLimitConfig{
+		Service:         rl,
+		Policies:        cfg.RateLimit.Policies,
+		EmailValidation: emailValidation,
+		BodyLimit:       int64(cfg.HTTP.BodyLimit),
+	})
+	if err != nil {
+		return fmt.Errorf("rateLimit.policies: %w", err)
+	}
 	r := chi.NewRouter()
 	r.Use(root.WithRequestID)
 	r.Use(root.WithRequestTime)
-	r.Use(root.WithRateLimit(rl.NewLimiter(cfg.RateLimit.IP)))
-	r.Use(root.WithBodyLimit(int64(cfg.HTTP.BodyLimit)))
+	r.Use(root.WithClientIP)
+	r.Use(root.WithFirewall())
+	r.Use(policies)
 	r.NotFound(root.NotFound())
 	r.MethodNotAllowed(root.MethodNotAllowed())
+	r.Route(cfg.Routes.Mail.Prefix, func(r chi.Router) {
+		r.Use(root.WithBodyLimit(int64(cfg.Mail.Webhook.BodyLimit)))
+		r.Post(cfg.Routes.Mail.Webhook, mailHandler.Webhook())
+	})
+	r.Post(cfg.Routes.Challenge, root.Challenge())
+	r.Route(cfg.Routes.Admin.Prefix, func(r chi.Router) {
+		r.Use(admin.WithSecret)
+		r.Use(root.WithBodyLimit(int64(cfg.HTTP.BodyLimit)))
+		r.Get(cfg.Routes.Admin.Bans, admin.GetBans())
+		r.Post(cfg.Routes.Admin.Bans, admin.CreateBan())
+		r.Delete(cfg.Routes.Admin.Ban, admin.DeleteBan())
+		r.Delete(cfg.Routes.Admin.Suppression, admin.DeleteSuppression())
+		if outbox != nil {
+			r.Get(cfg.Routes.Admin.Mails, admin.GetMails())
+			r.Delete(cfg.Routes.Admin.Mails, admin.DeleteMails())
+		}
+	})
 	r.Route(cfg.Routes.User.Prefix, func(r chi.Router) {
-		session := user.WithSession(userSessionToken, rl.NewLimiter(cfg.RateLimit.User.Session))
-		sudo := user.WithSession(userSudoToken, rl.NewLimiter(cfg.RateLimit.User.Sudo))
-		r.Post(cfg.Routes.User.Create, user.Create(rl.NewLimiter(cfg.RateLimit.User.Create)))
-		r.Post(cfg.Routes.User.ResetPassword, user.ResetPassword(rl.NewLimiter(cfg.RateLimit.User.ResetPassword)))
+		r.Use(root.WithBodyLimit(int64(cfg.HTTP.BodyLimit)))
+		session := user.WithSession(userSessionToken)
+		sudo := user.WithSession(userSudoToken)
+		r.Post(cfg.Routes.User.Create, user.Create())
+		r.Method(http.MethodPost, cfg.Routes.User.ResetPassword, user.ResetPassword())
 		r.Group(func(r chi.Router) {
 			r.Use(session)
 			r.Get(cfg.Routes.User.Get, user.Get())
 			r.Put(cfg.Routes.User.EditName, user.EditName())
+			r.Put(cfg.Routes.User.EditLocale, user.EditLocale())
+			r.Get(cfg.Routes.User.Notifications, user.GetNotifications())
+			r.Put(cfg.Routes.User.Notifications, user.EditNotifications())
 			r.Put(cfg.Routes.User.EditPassword, user.EditPassword())
 		})
 		r.Group(func(r chi.Router) {
@@ -85,33 +273,15 @@ func run(cfg *config.Config) error {
 			r.Delete(cfg.Routes.User.Delete, user.Delete())
 		})
 		r.Route(cfg.Routes.User.Token.Prefix, func(r chi.Router) {
-			r.Post(
-				cfg.Routes.User.Token.CreateConfirmation,
-				user.CreateConfirmationToken(
-					cfg.Mail.User.Confirmation,
-					rl.NewLimiter(cfg.RateLimit.User.CreateConfirmationToken),
-				),
-			)
-			r.Post(cfg.Routes.User.Token.CreateSession, user.CreateSessionToken(
-				rl.NewLimiter(cfg.RateLimit.User.CreateSessionToken.IP),
-				rl.NewLimiter(cfg.RateLimit.User.CreateSessionToken.Email),
-			))
-			r.Post(
-				cfg.Routes.User.Token.CreatePasswordReset,
-				user.CreatePasswordResetToken(
-					cfg.Mail.User.PasswordReset,
-					rl.NewLimiter(cfg.RateLimit.User.CreatePasswordResetToken),
-				),
-			)
-			r.With(session).Post(
-				cfg.Routes.User.Token.CreateSudo,
-				user.CreateSudoToken(
-					cfg.Mail.User.Sudo,
-					rl.NewLimiter(cfg.RateLimit.User.CreateSudoToken),
-				),
-			)
+			r.Post(cfg.Routes.User.Token.CreateConfirmation, user.CreateConfirmationToken(cfg.Mail.User.Confirmation))
+			r.Post(cfg.Routes.User.Token.CreateSession, user.CreateSessionToken())
+			r.Post(cfg.Routes.User.Token.CreatePasswordReset, user.CreatePasswordResetToken(cfg.Mail.User.PasswordReset))
+			r.With(session).Post(cfg.Routes.User.Token.CreateSudo, user.CreateSudoToken(cfg.Mail.User.Sudo))
 		})
 	})
+	if err = handler.CheckRateLimitPolicies(r, cfg.RateLimit.Policies); err != nil {
+		return fmt.Errorf("rateLimit.policies: %w", err)
+	}
 	server := &http.Server{
 		Addr:           net.JoinHostPort(cfg.HTTP.Host, cfg.HTTP.Port),
 		Handler:        r,
@@ -122,6 +292,19 @@ func run(cfg *config.Config) error {
 		MaxHeaderBytes: cfg.HTTP.HeaderLimit,
 		ErrorLog:       log.New(errorWriter, "", 0),
 	}
+	var metrics *http.Server
+	if cfg.HTTP.MetricsAddr != "" {
+		metrics = &http.Server{
+			Addr:     cfg.HTTP.MetricsAddr,
+			Handler:  expvar.Handler(),
+			ErrorLog: log.New(errorWriter, "", 0),
+		}
+		go func() {
+			if err := metrics.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logrus.Error(err)
+			}
+		}()
+	}
 	done := make(chan error, 1)
 	go func() {
 		interrupt := make(chan os.Signal, 1)
@@ -129,6 +312,9 @@ func run(cfg *config.Config) error {
 		<-interrupt
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
+		if metrics != nil {
+			metrics.Shutdown(ctx)
+		}
 		done <- server.Shutdown(ctx)
 	}()
 	if err = server.ListenAndServeTLS(cfg.HTTP.TLSCert, cfg.HTTP.TLSKey); err != nil && err != http.ErrServerClosed {
@@ -142,6 +328,12 @@ func main() {
 	if len(os.Args) < 2 {
 		logrus.Fatal("no config file provided")
 	}
+	if os.Args[1] == "calibrate" {
+		if err := calibrate(os.Args[2:]); err != nil {
+			logrus.Fatal(err)
+		}
+		return
+	}
 	cfg, err := config.New(os.Args[1])
 	if err != nil {
 		logrus.Fatal(err)
@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cyberwlodarczyk/auth/api/firewall"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func NewBanService(ctx context.Context, svc Service) (firewall.BanList, error) {
+	pool := svc.(*service).pool
+	if _, err := pool.Exec(
+		ctx,
+		`
+			CREATE TABLE IF NOT EXISTS ban_ (
+				id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+				prefix CIDR NOT NULL,
+				reason TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+				expires_at TIMESTAMP NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS ban__expires_at_idx ON ban_ (expires_at);
+		`,
+	); err != nil {
+		return nil, err
+	}
+	return &banService{pool}, nil
+}
+
+type banService struct {
+	pool *pgxpool.Pool
+}
+
+func (s *banService) Create(ctx context.Context, opts firewall.CreateBanOpts) (ban firewall.Ban, err error) {
+	err = s.pool.QueryRow(
+		ctx,
+		`
+			INSERT INTO ban_ (prefix, reason, expires_at)
+			VALUES ($1, $2, NOW() + make_interval(secs => $3))
+			RETURNING id, prefix, reason, created_at, expires_at
+		`,
+		opts.Prefix.Masked(),
+		opts.Reason,
+		opts.Duration.Seconds(),
+	).Scan(&ban.Id, &ban.Prefix, &ban.Reason, &ban.CreatedAt, &ban.ExpiresAt)
+	return
+}
+
+func (s *banService) GetActive(ctx context.Context) ([]firewall.Ban, error) {
+	rows, err := s.pool.Query(
+		ctx,
+		`
+			SELECT id, prefix, reason, created_at, expires_at
+			FROM ban_
+			WHERE expires_at > NOW()
+			ORDER BY id
+		`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (ban firewall.Ban, err error) {
+		err = row.Scan(&ban.Id, &ban.Prefix, &ban.Reason, &ban.CreatedAt, &ban.ExpiresAt)
+		return
+	})
+}
+
+func (s *banService) Delete(ctx context.Context, id int64) error {
+	err := isAffected(s.pool.Exec(
+		ctx,
+		"DELETE FROM ban_ WHERE id = $1",
+		id,
+	))
+	if errors.Is(err, ErrNotFound) {
+		return firewall.ErrNotBanned
+	}
+	return err
+}
@@ -0,0 +1,44 @@
+package postgres
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/cyberwlodarczyk/auth/api/firewall"
+)
+
+func TestBanService(t *testing.T) {
+	ctx := context.Background()
+	banSvc, err := NewBanService(ctx, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	active, err := banSvc.Create(ctx, firewall.CreateBanOpts{Prefix: netip.MustParsePrefix("192.0.2.17/24"), Reason: "abuse", Duration: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := netip.MustParsePrefix("192.0.2.0/24"); active.Prefix != expected {
+		t.Fatalf("expected prefix: %s, got: %s", expected, active.Prefix)
+	}
+	if !active.ExpiresAt.After(active.CreatedAt) {
+		t.Fatalf("expected expiration after creation, got: %s", active.ExpiresAt)
+	}
+	if _, err = banSvc.Create(ctx, firewall.CreateBanOpts{Prefix: netip.MustParsePrefix("2001:db8::1/128"), Reason: "expired", Duration: -time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	bans, err := banSvc.GetActive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bans) != 1 || bans[0].Id != active.Id {
+		t.Fatalf("expected active bans: %v, got: %v", []firewall.Ban{active}, bans)
+	}
+	if err = banSvc.Delete(ctx, active.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err = banSvc.Delete(ctx, active.Id); err != firewall.ErrNotBanned {
+		t.Fatalf("expected error: %v, got: %v", firewall.ErrNotBanned, err)
+	}
+}
@@ -8,8 +8,9 @@ import (
 )
 
 var (
-	ErrNotFound      = errors.New("postgres: record not found in the table")
-	ErrAlreadyExists = errors.New("postgres: record already exists in the table")
+	ErrNotFound          = errors.New("postgres: record not found in the table")
+	ErrAlreadyExists     = errors.New("postgres: record already exists in the table")
+	ErrConflictingEmails = errors.New("postgres: users share a canonical email")
 )
 
 func isFound(err error) error {
@@ -36,3 +37,11 @@ func isAffected(tag pgconn.CommandTag, err error) error {
 	}
 	return nil
 }
+
+func isReferenced(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return ErrNotFound
+	}
+	return err
+}
@@ -59,3 +59,19 @@ func TestIsAffected(t *testing.T) {
 		}
 	}
 }
+
+func TestIsReferenced(t *testing.T) {
+	tests := []struct {
+		given    error
+		expected error
+	}{
+		{nil, nil},
+		{err, err},
+		{&pgconn.PgError{Code: "23503"}, ErrNotFound},
+	}
+	for _, test := range tests {
+		if err = isReferenced(test.given); err != test.expected {
+			t.Fatalf("expected error: %v, got: %v", test.expected, err)
+		}
+	}
+}
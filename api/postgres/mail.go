@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/cyberwlodarczyk/auth/api/smtp"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func NewMailService(ctx context.Context, svc Service) (smtp.Queue, error) {
+	pool := svc.(*service).pool
+	if _, err := pool.Exec(
+		ctx,
+		`
+			CREATE TABLE IF NOT EXISTS mail_ (
+				id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+				sender TEXT NOT NULL,
+				recipient TEXT NOT NULL,
+				message BYTEA NOT NULL,
+				status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'dead')),
+				attempts INT NOT NULL DEFAULT 0,
+				last_error TEXT,
+				next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+				created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+				sent_at TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS mail__pending_idx ON mail_ (next_attempt_at) WHERE status = 'pending';
+		`,
+	); err != nil {
+		return nil, err
+	}
+	return &mailService{pool}, nil
+}
+
+type mailService struct {
+	pool *pgxpool.Pool
+}
+
+func (s *mailService) Enqueue(ctx context.Context, opts smtp.EnqueueMailOpts) (id int64, err error) {
+	err = s.pool.QueryRow(
+		ctx,
+		`
+			INSERT INTO mail_ (sender, recipient, message)
+			VALUES ($1, $2, $3)
+			RETURNING id
+		`,
+		opts.Sender,
+		opts.Recipient,
+		opts.Message,
+	).Scan(&id)
+	return
+}
+
+func (s *mailService) Claim(ctx context.Context, limit int, lease time.Duration) ([]smtp.QueuedMail, error) {
+	rows, err := s.pool.Query(
+		ctx,
+		`
+			UPDATE mail_
+			SET attempts = attempts + 1, next_attempt_at = NOW() + make_interval(secs => $2)
+			WHERE id IN (
+				SELECT id
+				FROM mail_
+				WHERE status = 'pending' AND next_attempt_at <= NOW()
+				ORDER BY next_attempt_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, sender, recipient, message, attempts
+		`,
+		limit,
+		lease.Seconds(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (mail smtp.QueuedMail, err error) {
+		err = row.Scan(&mail.Id, &mail.Sender, &mail.Recipient, &mail.Message, &mail.Attempts)
+		return
+	})
+}
+
+func (s *mailService) MarkSent(ctx context.Context, id int64) error {
+	return isAffected(s.pool.Exec(
+		ctx,
+		`
+			UPDATE mail_
+			SET status = 'sent', message = '', last_error = NULL, sent_at = NOW()
+			WHERE id = $1
+		`,
+		id,
+	))
+}
+
+func (s *mailService) MarkFailed(ctx context.Context, id int64, reason string, delay time.Duration) error {
+	return isAffected(s.pool.Exec(
+		ctx,
+		`
+			UPDATE mail_
+			SET last_error = $2, next_attempt_at = NOW() + make_interval(secs => $3)
+			WHERE id = $1
+		`,
+		id,
+		reason,
+		delay.Seconds(),
+	))
+}
+
+func (s *mailService) MarkDead(ctx context.Context, id int64, reason string) error {
+	return isAffected(s.pool.Exec(
+		ctx,
+		"UPDATE mail_ SET status = 'dead', last_error = $2 WHERE id = $1",
+		id,
+		reason,
+	))
+}
@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cyberwlodarczyk/auth/api/smtp"
+)
+
+const (
+	sender    = "noreply@foo.com"
+	recipient = "bar@foo.com"
+)
+
+var message = []byte("Subject: Hello\r\n\r\nHello, World!\r\n")
+
+func TestMailService(t *testing.T) {
+	ctx := context.Background()
+	mailSvc, err := NewMailService(ctx, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id1, err := mailSvc.Enqueue(ctx, smtp.EnqueueMailOpts{Sender: sender, Recipient: recipient, Message: message})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id2, err := mailSvc.Enqueue(ctx, smtp.EnqueueMailOpts{Sender: sender, Recipient: recipient, Message: message})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mails, err := mailSvc.Claim(ctx, 1, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mails) != 1 {
+		t.Fatalf("expected mails: %d, got: %d", 1, len(mails))
+	}
+	mail := mails[0]
+	if mail.Id != id1 || mail.Sender != sender || mail.Recipient != recipient || !bytes.Equal(mail.Message, message) || mail.Attempts != 1 {
+		t.Fatalf("unexpected mail: %v", mail)
+	}
+	if err = mailSvc.MarkSent(ctx, id1); err != nil {
+		t.Fatal(err)
+	}
+	mails, err = mailSvc.Claim(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mails) != 1 || mails[0].Id != id2 {
+		t.Fatalf("expected mail: %d, got: %v", id2, mails)
+	}
+	if mails, err = mailSvc.Claim(ctx, 10, time.Minute); err != nil || len(mails) != 0 {
+		t.Fatalf("expected leased mail to be skipped, got: %v, %v", mails, err)
+	}
+	if err = mailSvc.MarkFailed(ctx, id2, "connection refused", 0); err != nil {
+		t.Fatal(err)
+	}
+	mails, err = mailSvc.Claim(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mails) != 1 || mails[0].Attempts != 2 {
+		t.Fatalf("expected retried mail with %d attempts, got: %v", 2, mails)
+	}
+	if err = mailSvc.MarkDead(ctx, id2, "connection refused"); err != nil {
+		t.Fatal(err)
+	}
+	for id, expected := range map[int64]string{id1: smtp.MailStatusSent, id2: smtp.MailStatusDead} {
+		status, err := getMailStatus(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != expected {
+			t.Fatalf("expected status: %s, got: %s", expected, status)
+		}
+	}
+	if mails, err = mailSvc.Claim(ctx, 10, 0); err != nil || len(mails) != 0 {
+		t.Fatalf("expected no pending mails, got: %v, %v", mails, err)
+	}
+	if err = mailSvc.MarkSent(ctx, id2+1); err != ErrNotFound {
+		t.Fatalf("expected error: %v, got: %v", ErrNotFound, err)
+	}
+	if _, err = getMailStatus(ctx, id2+1); err != ErrNotFound {
+		t.Fatalf("expected error: %v, got: %v", ErrNotFound, err)
+	}
+}
+
+func getMailStatus(ctx context.Context, id int64) (status string, err error) {
+	err = isFound(svc.(*service).pool.QueryRow(
+		ctx,
+		"SELECT status FROM mail_ WHERE id = $1",
+		id,
+	).Scan(&status))
+	return
+}
@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type NotificationPreferences struct {
+	NewLogin bool `json:"newLogin"`
+}
+
+var DefaultNotificationPreferences = NotificationPreferences{
+	NewLogin: true,
+}
+
+type NotificationService interface {
+	GetPreferences(context.Context, int64) (NotificationPreferences, error)
+	EditPreferences(context.Context, int64, NotificationPreferences) error
+	TouchDevice(context.Context, int64, string) (bool, error)
+}
+
+func NewNotificationService(ctx context.Context, svc Service) (NotificationService, error) {
+	pool := svc.(*service).pool
+	if _, err := pool.Exec(
+		ctx,
+		`
+			CREATE TABLE IF NOT EXISTS notification_preference_ (
+				user_id BIGINT PRIMARY KEY REFERENCES user_ (id) ON DELETE CASCADE,
+				new_login BOOLEAN NOT NULL
+			);
+			CREATE TABLE IF NOT EXISTS user_device_ (
+				user_id BIGINT NOT NULL REFERENCES user_ (id) ON DELETE CASCADE,
+				fingerprint TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+				last_seen_at TIMESTAMP NOT NULL DEFAULT NOW(),
+				PRIMARY KEY (user_id, fingerprint)
+			);
+		`,
+	); err != nil {
+		return nil, err
+	}
+	return &notificationService{pool}, nil
+}
+
+type notificationService struct {
+	pool *pgxpool.Pool
+}
+
+func (s *notificationService) GetPreferences(ctx context.Context, userID int64) (prefs NotificationPreferences, err error) {
+	err = s.pool.QueryRow(
+		ctx,
+		"SELECT new_login FROM notification_preference_ WHERE user_id = $1",
+		userID,
+	).Scan(&prefs.NewLogin)
+	if isFound(err) == ErrNotFound {
+		return DefaultNotificationPreferences, nil
+	}
+	return
+}
+
+func (s *notificationService) EditPreferences(ctx context.Context, userID int64, prefs NotificationPreferences) error {
+	_, err := s.pool.Exec(
+		ctx,
+		`
+			INSERT INTO notification_preference_ (user_id, new_login)
+			VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE SET new_login = $2
+		`,
+		userID,
+		prefs.NewLogin,
+	)
+	return isReferenced(err)
+}
+
+func (s *notificationService) TouchDevice(ctx context.Context, userID int64, fingerprint string) (created bool, err error) {
+	err = s.pool.QueryRow(
+		ctx,
+		`
+			WITH known AS (
+				SELECT EXISTS (SELECT 1 FROM user_device_ WHERE user_id = $1) AS seeded
+			)
+			INSERT INTO user_device_ (user_id, fingerprint)
+			VALUES ($1, $2)
+			ON CONFLICT (user_id, fingerprint) DO UPDATE SET last_seen_at = NOW()
+			RETURNING xmax = 0 AND (SELECT seeded FROM known)
+		`,
+		userID,
+		fingerprint,
+	).Scan(&created)
+	err = isReferenced(err)
+	return
+}
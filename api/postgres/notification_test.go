@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNotificationService(t *testing.T) {
+	ctx := context.Background()
+	userSvc, err := NewUserService(ctx, svc, strings.ToLower)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notificationSvc, err := NewNotificationService(ctx, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := userSvc.Create(ctx, CreateUserOpts{Email: "notify@foo.com", CanonicalEmail: "notify@foo.com", Name: name1, Password: password1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefs, err := notificationSvc.GetPreferences(ctx, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefs != DefaultNotificationPreferences {
+		t.Fatalf("expected preferences: %v, got: %v", DefaultNotificationPreferences, prefs)
+	}
+	expected := NotificationPreferences{NewLogin: false}
+	if err = notificationSvc.EditPreferences(ctx, user.Id, expected); err != nil {
+		t.Fatal(err)
+	}
+	if prefs, err = notificationSvc.GetPreferences(ctx, user.Id); err != nil || prefs != expected {
+		t.Fatalf("expected preferences: %v, got: %v, %v", expected, prefs, err)
+	}
+	for _, test := range []struct {
+		fingerprint string
+		created     bool
+	}{
+		{"laptop", false},
+		{"laptop", false},
+		{"phone", true},
+	} {
+		created, err := notificationSvc.TouchDevice(ctx, user.Id, test.fingerprint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if created != test.created {
+			t.Fatalf("expected created: %t for %s, got: %t", test.created, test.fingerprint, created)
+		}
+	}
+	if err = userSvc.Delete(ctx, user.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err = notificationSvc.EditPreferences(ctx, user.Id, expected); err != ErrNotFound {
+		t.Fatalf("expected error: %v, got: %v", ErrNotFound, err)
+	}
+	if _, err = notificationSvc.TouchDevice(ctx, user.Id, "laptop"); err != ErrNotFound {
+		t.Fatalf("expected error: %v, got: %v", ErrNotFound, err)
+	}
+}
@@ -0,0 +1,276 @@
+package postgres
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cyberwlodarczyk/auth/api/ratelimit"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func NewRateLimitService(ctx context.Context, svc Service, cleanupInterval time.Duration, errorLog *log.Logger) (ratelimit.Service, error) {
+	pool := svc.(*service).pool
+	if _, err := pool.Exec(
+		ctx,
+		`
+			CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_ (
+				key TEXT PRIMARY KEY,
+				tat TIMESTAMPTZ NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS rate_limit__tat_idx ON rate_limit_ (tat);
+			CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_log_ (
+				key TEXT PRIMARY KEY,
+				hits TIMESTAMPTZ[] NOT NULL,
+				expires_at TIMESTAMPTZ NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS rate_limit_log__expires_at_idx ON rate_limit_log_ (expires_at);
+			CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_window_ (
+				key TEXT PRIMARY KEY,
+				window_start TIMESTAMPTZ NOT NULL,
+				previous_count INTEGER NOT NULL,
+				current_count INTEGER NOT NULL,
+				expires_at TIMESTAMPTZ NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS rate_limit_window__expires_at_idx ON rate_limit_window_ (expires_at);
+		`,
+	); err != nil {
+		return nil, err
+	}
+	if errorLog == nil {
+		errorLog = log.Default()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &rateLimitService{
+		pool:     pool,
+		errorLog: errorLog,
+		cancel:   cancel,
+		limiters: make(map[string]*rateLimiter),
+	}
+	s.wg.Add(1)
+	go s.cleanup(ctx, cleanupInterval)
+	return s, nil
+}
+
+type rateLimitService struct {
+	pool     *pgxpool.Pool
+	errorLog *log.Logger
+	cancel   context.CancelFunc
+	limiters map[string]*rateLimiter
+	keys     ratelimit.KeyCounts
+	wg       sync.WaitGroup
+	mutex    sync.Mutex
+}
+
+func (s *rateLimitService) cleanup(ctx context.Context, interval time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(max(interval, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.pool.Exec(
+				ctx,
+				`
+					DELETE FROM rate_limit_ WHERE tat < NOW();
+					DELETE FROM rate_limit_log_ WHERE expires_at < NOW();
+					DELETE FROM rate_limit_window_ WHERE expires_at < NOW();
+				`,
+			); err != nil && ctx.Err() == nil {
+				s.errorLog.Print(err)
+			}
+		}
+	}
+}
+
+func (s *rateLimitService) NewLimiter(name string, p ratelimit.Params) ratelimit.Limiter {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	l := &rateLimiter{pool: s.pool, prefix: ratelimit.EscapeName(name) + ":", params: p}
+	if existing, ok := s.limiters[name]; ok {
+		l.counter = existing.counter
+	} else {
+		l.counter = &ratelimit.Counter{}
+	}
+	s.limiters[name] = l
+	return l
+}
+
+func (s *rateLimitService) Stats(ctx context.Context) (map[string]ratelimit.Stats, error) {
+	s.mutex.Lock()
+	limiters := make(map[string]*rateLimiter, len(s.limiters))
+	for name, l := range s.limiters {
+		limiters[name] = l
+	}
+	s.mutex.Unlock()
+	counts, err := s.keys.Get(ctx, s.countKeys)
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[string]ratelimit.Stats, len(limiters))
+	for name, l := range limiters {
+		stats[name] = l.counter.Stats(counts[ratelimit.EscapeName(name)])
+	}
+	return stats, nil
+}
+
+func (s *rateLimitService) countKeys(ctx context.Context) (map[string]int, error) {
+	rows, err := s.pool.Query(
+		ctx,
+		`
+			SELECT split_part(key, ':', 1), COUNT(*)
+			FROM (
+				SELECT key FROM rate_limit_ WHERE tat > NOW()
+				UNION ALL
+				SELECT key FROM rate_limit_log_ WHERE expires_at > NOW()
+				UNION ALL
+				SELECT key FROM rate_limit_window_ WHERE expires_at > NOW()
+			) AS keys
+			GROUP BY 1
+		`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int)
+	var name string
+	var count int
+	_, err = pgx.ForEachRow(rows, []any{&name, &count}, func() error {
+		counts[name] = count
+		return nil
+	})
+	return counts, err
+}
+
+func (s *rateLimitService) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+type rateLimiter struct {
+	pool    *pgxpool.Pool
+	prefix  string
+	params  ratelimit.Params
+	counter *ratelimit.Counter
+}
+
+func (l *rateLimiter) Allow(ctx context.Context, key string) (ratelimit.Result, error) {
+	if l.params.Burst < 1 {
+		return l.counter.Count(l.params.Denied()), nil
+	}
+	switch l.params.Algorithm {
+	case ratelimit.AlgorithmSlidingLog:
+		return l.allowLog(ctx, key)
+	case ratelimit.AlgorithmSlidingWindow, ratelimit.AlgorithmQuota:
+		return l.allowWindow(ctx, key)
+	}
+	var now, tat time.Time
+	interval := l.params.Interval().Seconds()
+	err := l.pool.QueryRow(
+		ctx,
+		`
+			INSERT INTO rate_limit_ AS r (key, tat)
+			VALUES ($1, NOW() + make_interval(secs => $2))
+			ON CONFLICT (key) DO UPDATE
+			SET tat = GREATEST(r.tat, NOW()) + make_interval(secs => $2)
+			WHERE GREATEST(r.tat, NOW()) + make_interval(secs => $2) - make_interval(secs => $3) <= NOW()
+			RETURNING NOW(), tat
+		`,
+		l.prefix+key,
+		interval,
+		l.params.Tolerance().Seconds(),
+	).Scan(&now, &tat)
+	if err == nil {
+		return l.counter.Count(l.params.GCRA(true, now, tat)), nil
+	}
+	if err = isFound(err); err != ErrNotFound {
+		return ratelimit.Result{}, err
+	}
+	if err = l.pool.QueryRow(
+		ctx,
+		"SELECT NOW(), GREATEST(tat, NOW()) FROM rate_limit_ WHERE key = $1",
+		l.prefix+key,
+	).Scan(&now, &tat); err != nil {
+		return ratelimit.Result{}, err
+	}
+	return l.counter.Count(l.params.GCRA(false, now, tat)), nil
+}
+
+func (l *rateLimiter) allowLog(ctx context.Context, key string) (result ratelimit.Result, err error) {
+	err = pgx.BeginFunc(ctx, l.pool, func(tx pgx.Tx) error {
+		var now time.Time
+		var hits []time.Time
+		if err := tx.QueryRow(
+			ctx,
+			`
+				INSERT INTO rate_limit_log_ (key, hits, expires_at)
+				VALUES ($1, '{}', NOW())
+				ON CONFLICT (key) DO UPDATE SET key = EXCLUDED.key
+				RETURNING NOW(), hits
+			`,
+			l.prefix+key,
+		).Scan(&now, &hits); err != nil {
+			return err
+		}
+		hits, allowed := l.params.TakeLog(now, hits)
+		newest := hits[len(hits)-1]
+		if _, err := tx.Exec(
+			ctx,
+			"UPDATE rate_limit_log_ SET hits = $2, expires_at = $3 WHERE key = $1",
+			l.prefix+key,
+			hits,
+			newest.Add(l.params.Window),
+		); err != nil {
+			return err
+		}
+		result = l.params.LogResult(allowed, now, len(hits), hits[0], newest)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	return l.counter.Count(result), nil
+}
+
+func (l *rateLimiter) allowWindow(ctx context.Context, key string) (result ratelimit.Result, err error) {
+	err = pgx.BeginFunc(ctx, l.pool, func(tx pgx.Tx) error {
+		var now time.Time
+		var w ratelimit.Window
+		if err := tx.QueryRow(
+			ctx,
+			`
+				INSERT INTO rate_limit_window_ (key, window_start, previous_count, current_count, expires_at)
+				VALUES ($1, 'epoch', 0, 0, NOW())
+				ON CONFLICT (key) DO UPDATE SET key = EXCLUDED.key
+				RETURNING NOW(), window_start, previous_count, current_count
+			`,
+			l.prefix+key,
+		).Scan(&now, &w.Start, &w.Previous, &w.Current); err != nil {
+			return err
+		}
+		w, allowed := l.params.TakeWindow(now, w)
+		result = l.params.WindowResult(allowed, now, w)
+		_, err := tx.Exec(
+			ctx,
+			`
+				UPDATE rate_limit_window_
+				SET window_start = $2, previous_count = $3, current_count = $4, expires_at = $5
+				WHERE key = $1
+			`,
+			l.prefix+key,
+			w.Start,
+			w.Previous,
+			w.Current,
+			now.Add(result.Reset),
+		)
+		return err
+	})
+	if err != nil {
+		return
+	}
+	return l.counter.Count(result), nil
+}
@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cyberwlodarczyk/auth/api/ratelimit"
+)
+
+func TestRateLimitService(t *testing.T) {
+	ctx := context.Background()
+	rl, err := NewRateLimitService(ctx, svc, time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rl.Close()
+	limiter := rl.NewLimiter("test", ratelimit.Params{Rate: 1, Burst: 2})
+	for i, expected := range []bool{true, true, false} {
+		result, err := limiter.Allow(ctx, "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Allowed != expected {
+			t.Fatalf("expected allowed: %t on request %d, got: %t", expected, i+1, result.Allowed)
+		}
+	}
+	if result, err := limiter.Allow(ctx, "foo"); err != nil || result.RetryAfter <= 0 || result.Remaining != 0 {
+		t.Fatalf("expected retry after with no remaining, got: %+v, %v", result, err)
+	}
+	if result, err := limiter.Allow(ctx, "bar"); err != nil || !result.Allowed || result.Remaining != 1 {
+		t.Fatalf("expected separate key to be allowed, got: %+v, %v", result, err)
+	}
+	if result, err := rl.NewLimiter("other", ratelimit.Params{Rate: 1, Burst: 1}).Allow(ctx, "foo"); err != nil || !result.Allowed {
+		t.Fatalf("expected separate limiter to be allowed, got: %+v, %v", result, err)
+	}
+	stats, err := rl.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (ratelimit.Stats{Keys: 2, Allows: 3, Denies: 2}); stats["test"] != expected {
+		t.Fatalf("expected stats: %+v, got: %+v", expected, stats["test"])
+	}
+}
+
+func TestRateLimitServiceWindow(t *testing.T) {
+	ctx := context.Background()
+	for _, algorithm := range []string{ratelimit.AlgorithmSlidingLog, ratelimit.AlgorithmSlidingWindow, ratelimit.AlgorithmQuota} {
+		rl, err := NewRateLimitService(ctx, svc, time.Minute, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rl.Close()
+		limiter := rl.NewLimiter(algorithm, ratelimit.Params{Algorithm: algorithm, Burst: 2, Window: 24 * time.Hour})
+		for i, expected := range []bool{true, true, false} {
+			result, err := limiter.Allow(ctx, "foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Allowed != expected {
+				t.Fatalf("expected allowed: %t on request %d for %s, got: %t", expected, i+1, algorithm, result.Allowed)
+			}
+			if !expected && result.RetryAfter <= 0 {
+				t.Fatalf("expected positive retry after for %s, got: %v", algorithm, result.RetryAfter)
+			}
+		}
+		stats, err := rl.Stats(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := (ratelimit.Stats{Keys: 1, Allows: 2, Denies: 1}); stats[algorithm] != expected {
+			t.Fatalf("expected stats: %+v for %s, got: %+v", expected, algorithm, stats[algorithm])
+		}
+	}
+}
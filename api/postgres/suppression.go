@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cyberwlodarczyk/auth/api/smtp"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func NewSuppressionService(ctx context.Context, svc Service, normalize func(string) string) (smtp.SuppressionList, error) {
+	pool := svc.(*service).pool
+	if err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(
+			ctx,
+			`
+				CREATE TABLE IF NOT EXISTS suppression_ (
+					email TEXT PRIMARY KEY,
+					kind TEXT NOT NULL CHECK (kind IN ('bounce', 'complaint')),
+					reason TEXT NOT NULL,
+					created_at TIMESTAMP NOT NULL DEFAULT NOW()
+				);
+				LOCK TABLE suppression_ IN EXCLUSIVE MODE;
+			`,
+		); err != nil {
+			return err
+		}
+		return canonicalizeSuppressions(ctx, tx, normalize)
+	}); err != nil {
+		return nil, err
+	}
+	return &suppressionService{pool, normalize}, nil
+}
+
+func canonicalizeSuppressions(ctx context.Context, tx pgx.Tx, normalize func(string) string) error {
+	rows, err := tx.Query(ctx, "SELECT email FROM suppression_")
+	if err != nil {
+		return err
+	}
+	emails, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+	for _, email := range emails {
+		canonical := normalize(email)
+		if canonical == email {
+			continue
+		}
+		if _, err = tx.Exec(
+			ctx,
+			`
+				INSERT INTO suppression_ (email, kind, reason, created_at)
+				SELECT $2, kind, reason, created_at FROM suppression_ WHERE email = $1
+				ON CONFLICT (email) DO NOTHING;
+			`,
+			email,
+			canonical,
+		); err != nil {
+			return err
+		}
+		if _, err = tx.Exec(ctx, "DELETE FROM suppression_ WHERE email = $1", email); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type suppressionService struct {
+	pool      *pgxpool.Pool
+	normalize func(string) string
+}
+
+func (s *suppressionService) Suppress(ctx context.Context, opts smtp.SuppressOpts) error {
+	_, err := s.pool.Exec(
+		ctx,
+		`
+			INSERT INTO suppression_ (email, kind, reason)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (email) DO UPDATE SET kind = $2, reason = $3, created_at = NOW()
+		`,
+		s.normalize(opts.Email),
+		opts.Kind,
+		opts.Reason,
+	)
+	return err
+}
+
+func (s *suppressionService) IsSuppressed(ctx context.Context, email string) (suppressed bool, err error) {
+	err = s.pool.QueryRow(
+		ctx,
+		"SELECT EXISTS (SELECT 1 FROM suppression_ WHERE email = $1)",
+		s.normalize(email),
+	).Scan(&suppressed)
+	return
+}
+
+func (s *suppressionService) Remove(ctx context.Context, email string) error {
+	err := isAffected(s.pool.Exec(
+		ctx,
+		"DELETE FROM suppression_ WHERE email = $1",
+		s.normalize(email),
+	))
+	if errors.Is(err, ErrNotFound) {
+		return smtp.ErrNotSuppressed
+	}
+	return err
+}
@@ -0,0 +1,38 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cyberwlodarczyk/auth/api/smtp"
+)
+
+func TestSuppressionService(t *testing.T) {
+	ctx := context.Background()
+	suppressionSvc, err := NewSuppressionService(ctx, svc, strings.ToLower)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = suppressionSvc.Suppress(ctx, smtp.SuppressOpts{Email: upperEmail1, Kind: smtp.SuppressionBounce, Reason: "550 5.1.1 user unknown"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = suppressionSvc.Suppress(ctx, smtp.SuppressOpts{Email: email1, Kind: smtp.SuppressionComplaint, Reason: "abuse"}); err != nil {
+		t.Fatal(err)
+	}
+	for email, expected := range map[string]bool{email1: true, upperEmail1: true, email2: false} {
+		suppressed, err := suppressionSvc.IsSuppressed(ctx, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if suppressed != expected {
+			t.Fatalf("expected suppressed: %t for %s, got: %t", expected, email, suppressed)
+		}
+	}
+	if err = suppressionSvc.Remove(ctx, upperEmail1); err != nil {
+		t.Fatal(err)
+	}
+	if err = suppressionSvc.Remove(ctx, email1); err != smtp.ErrNotSuppressed {
+		t.Fatalf("expected error: %v, got: %v", smtp.ErrNotSuppressed, err)
+	}
+}
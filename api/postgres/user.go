@@ -2,48 +2,122 @@ package postgres
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type User struct {
-	Id        int64     `json:"id"`
-	Email     string    `json:"email"`
-	Name      string    `json:"name"`
-	Password  string    `json:"-"`
-	CreatedAt time.Time `json:"createdAt"`
+	Id             int64     `json:"id"`
+	Email          string    `json:"email"`
+	CanonicalEmail string    `json:"-"`
+	Name           string    `json:"name"`
+	Locale         string    `json:"locale"`
+	Password       string    `json:"-"`
+	CreatedAt      time.Time `json:"createdAt"`
 }
 
 type UserService interface {
 	GetById(context.Context, int64) (User, error)
 	GetByEmail(context.Context, string) (User, error)
 	Create(context.Context, CreateUserOpts) (User, error)
-	EditEmail(context.Context, int64, string) error
+	EditEmail(context.Context, int64, string, string) error
 	EditName(context.Context, int64, string) error
+	EditLocale(context.Context, int64, string) error
 	EditPassword(context.Context, int64, string) error
 	Delete(context.Context, int64) error
 }
 
-func NewUserService(ctx context.Context, svc Service) (UserService, error) {
+func NewUserService(ctx context.Context, svc Service, normalize func(string) string) (UserService, error) {
 	pool := svc.(*service).pool
-	if _, err := pool.Exec(
-		ctx,
-		`
-			CREATE TABLE IF NOT EXISTS user_ (
-				id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
-				email TEXT NOT NULL UNIQUE,
-				name TEXT NOT NULL,
-				password TEXT NOT NULL,
-				created_at TIMESTAMP NOT NULL DEFAULT NOW()
-			)
-		`,
-	); err != nil {
+	if err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(
+			ctx,
+			`
+				CREATE TABLE IF NOT EXISTS user_ (
+					id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+					email TEXT NOT NULL UNIQUE,
+					canonical_email TEXT NOT NULL UNIQUE,
+					name TEXT NOT NULL,
+					locale TEXT NOT NULL DEFAULT '',
+					password TEXT NOT NULL,
+					created_at TIMESTAMP NOT NULL DEFAULT NOW()
+				);
+				ALTER TABLE user_ ADD COLUMN IF NOT EXISTS canonical_email TEXT;
+				ALTER TABLE user_ ADD COLUMN IF NOT EXISTS locale TEXT NOT NULL DEFAULT '';
+				LOCK TABLE user_ IN SHARE ROW EXCLUSIVE MODE;
+			`,
+		); err != nil {
+			return err
+		}
+		if err := backfillCanonicalEmails(ctx, tx, normalize); err != nil {
+			return err
+		}
+		_, err := tx.Exec(
+			ctx,
+			`
+				ALTER TABLE user_ ALTER COLUMN canonical_email SET NOT NULL;
+				CREATE UNIQUE INDEX IF NOT EXISTS user__canonical_email_key ON user_ (canonical_email);
+			`,
+		)
+		return err
+	}); err != nil {
 		return nil, err
 	}
 	return &userService{pool}, nil
 }
 
+func backfillCanonicalEmails(ctx context.Context, tx pgx.Tx, normalize func(string) string) error {
+	rows, err := tx.Query(ctx, "SELECT id, email FROM user_ WHERE canonical_email IS NULL ORDER BY id")
+	if err != nil {
+		return err
+	}
+	type pending struct {
+		id    int64
+		email string
+	}
+	var users []pending
+	for rows.Next() {
+		var u pending
+		if err = rows.Scan(&u.id, &u.email); err != nil {
+			rows.Close()
+			return err
+		}
+		users = append(users, u)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	var conflicts []string
+	for _, u := range users {
+		canonical := normalize(u.email)
+		var id int64
+		err = tx.QueryRow(
+			ctx,
+			"SELECT id FROM user_ WHERE canonical_email = $1",
+			canonical,
+		).Scan(&id)
+		if err == nil {
+			conflicts = append(conflicts, fmt.Sprintf("%d and %d (%s)", id, u.id, canonical))
+			continue
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		if _, err = tx.Exec(ctx, "UPDATE user_ SET canonical_email = $2 WHERE id = $1", u.id, canonical); err != nil {
+			return err
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("%w: %s", ErrConflictingEmails, strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
 type userService struct {
 	pool *pgxpool.Pool
 }
@@ -52,61 +126,68 @@ func (s *userService) GetById(ctx context.Context, id int64) (user User, err err
 	err = isFound(s.pool.QueryRow(
 		ctx,
 		`
-			SELECT id, email, name, password, created_at
+			SELECT id, email, canonical_email, name, locale, password, created_at
 			FROM user_
 			WHERE id = $1
 		`,
 		id,
-	).Scan(&user.Id, &user.Email, &user.Name, &user.Password, &user.CreatedAt))
+	).Scan(&user.Id, &user.Email, &user.CanonicalEmail, &user.Name, &user.Locale, &user.Password, &user.CreatedAt))
 	return
 }
 
-func (s *userService) GetByEmail(ctx context.Context, email string) (user User, err error) {
+func (s *userService) GetByEmail(ctx context.Context, canonicalEmail string) (user User, err error) {
 	err = isFound(s.pool.QueryRow(
 		ctx,
 		`
-			SELECT id, email, name, password, created_at
+			SELECT id, email, canonical_email, name, locale, password, created_at
 			FROM user_
-			WHERE email = $1
+			WHERE canonical_email = $1
 		`,
-		email,
-	).Scan(&user.Id, &user.Email, &user.Name, &user.Password, &user.CreatedAt))
+		canonicalEmail,
+	).Scan(&user.Id, &user.Email, &user.CanonicalEmail, &user.Name, &user.Locale, &user.Password, &user.CreatedAt))
 	return
 }
 
 type CreateUserOpts struct {
-	Email    string
-	Name     string
-	Password string
+	Email          string
+	CanonicalEmail string
+	Name           string
+	Locale         string
+	Password       string
 }
 
 func (s *userService) Create(ctx context.Context, opts CreateUserOpts) (user User, err error) {
 	err = isUnique(s.pool.QueryRow(
 		ctx,
 		`
-			INSERT INTO user_ (email, name, password)
-			VALUES ($1, $2, $3)
+			INSERT INTO user_ (email, canonical_email, name, locale, password)
+			VALUES ($1, $2, $3, $4, $5)
 			RETURNING id, created_at
 		`,
 		opts.Email,
+		opts.CanonicalEmail,
 		opts.Name,
+		opts.Locale,
 		opts.Password,
 	).Scan(&user.Id, &user.CreatedAt))
 	if err != nil {
 		return
 	}
 	user.Email = opts.Email
+	user.CanonicalEmail = opts.CanonicalEmail
 	user.Name = opts.Name
+	user.Locale = opts.Locale
 	user.Password = opts.Password
 	return
 }
 
-func (s *userService) EditEmail(ctx context.Context, id int64, email string) error {
+func (s *userService) EditEmail(ctx context.Context, id int64, email, canonicalEmail string) error {
 	return isUnique(isAffected(s.pool.Exec(
 		ctx,
-		"UPDATE user_ SET email = $2 WHERE id = $1",
+		"UPDATE user_ SET email = $2, canonical_email = $3 WHERE id = $1",
 		id,
 		email,
+		canonicalEmail,
 	)))
 }
 
@@ -119,6 +200,15 @@ func (s *userService) EditName(ctx context.Context, id int64, name string) error
 	))
 }
 
+func (s *userService) EditLocale(ctx context.Context, id int64, locale string) error {
+	return isAffected(s.pool.Exec(
+		ctx,
+		"UPDATE user_ SET locale = $2 WHERE id = $1",
+		id,
+		locale,
+	))
+}
+
 func (s *userService) EditPassword(ctx context.Context, id int64, password string) error {
 	return isAffected(s.pool.Exec(
 		ctx,
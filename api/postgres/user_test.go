@@ -2,33 +2,42 @@ package postgres
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 )
 
 const (
-	email1    = "bar@foo.com"
-	email2    = "baz@foo.com"
-	name1     = "john"
-	name2     = "bob"
-	password1 = "pa$$word123"
-	password2 = "s3cr3t!"
+	email1      = "bar@foo.com"
+	email2      = "baz@foo.com"
+	upperEmail1 = "Bar@foo.com"
+	name1       = "john"
+	name2       = "bob"
+	locale1     = "en"
+	locale2     = "pl"
+	password1   = "pa$$word123"
+	password2   = "s3cr3t!"
 )
 
 func TestUserService(t *testing.T) {
 	ctx := context.Background()
-	userSvc, err := NewUserService(ctx, svc)
+	userSvc, err := NewUserService(ctx, svc, strings.ToLower)
 	if err != nil {
 		t.Fatal(err)
 	}
-	expected1, err := userSvc.Create(ctx, CreateUserOpts{Email: email1, Name: name1, Password: password1})
+	expected1, err := userSvc.Create(ctx, CreateUserOpts{Email: email1, CanonicalEmail: email1, Name: name1, Locale: locale1, Password: password1})
 	if err != nil {
 		t.Fatal(err)
 	}
 	id1 := expected1.Id
-	if _, err = userSvc.Create(ctx, CreateUserOpts{Email: email1, Name: name1, Password: password1}); err != ErrAlreadyExists {
+	if _, err = userSvc.Create(ctx, CreateUserOpts{Email: email1, CanonicalEmail: email1, Name: name1, Password: password1}); err != ErrAlreadyExists {
 		t.Fatalf("expected error: %v, got: %v", ErrAlreadyExists, err)
 	}
-	expected2, err := userSvc.Create(ctx, CreateUserOpts{Email: email2, Name: name2, Password: password2})
+	if _, err = userSvc.Create(ctx, CreateUserOpts{Email: upperEmail1, CanonicalEmail: email1, Name: name1, Password: password1}); err != ErrAlreadyExists {
+		t.Fatalf("expected error: %v, got: %v", ErrAlreadyExists, err)
+	}
+	expected2, err := userSvc.Create(ctx, CreateUserOpts{Email: email2, CanonicalEmail: email2, Name: name2, Password: password2})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -47,13 +56,17 @@ func TestUserService(t *testing.T) {
 	if user != expected2 {
 		t.Fatalf("expected user: %v, got: %v", expected2, user)
 	}
-	if err = userSvc.EditEmail(ctx, id1, email2); err != ErrAlreadyExists {
+	if err = userSvc.EditEmail(ctx, id1, email2, email2); err != ErrAlreadyExists {
 		t.Fatalf("expected error: %v, got: %v", ErrAlreadyExists, err)
 	}
 	if err = userSvc.EditName(ctx, id1, name2); err != nil {
 		t.Fatal(err)
 	}
 	expected1.Name = name2
+	if err = userSvc.EditLocale(ctx, id1, locale2); err != nil {
+		t.Fatal(err)
+	}
+	expected1.Locale = locale2
 	if err = userSvc.EditPassword(ctx, id2, password1); err != nil {
 		t.Fatal(err)
 	}
@@ -78,7 +91,7 @@ func TestUserService(t *testing.T) {
 	if err = userSvc.Delete(ctx, id2); err != ErrNotFound {
 		t.Fatalf("expected error: %v, got: %v", ErrNotFound, err)
 	}
-	if err = userSvc.EditEmail(ctx, id1, email2); err != nil {
+	if err = userSvc.EditEmail(ctx, id1, email2, email2); err != nil {
 		t.Fatal(err)
 	}
 	expected1.Email = email2
@@ -98,13 +111,67 @@ func TestUserService(t *testing.T) {
 	if _, err = userSvc.GetByEmail(ctx, email1); err != ErrNotFound {
 		t.Fatalf("expected error: %v, got: %v", ErrNotFound, err)
 	}
-	if err = userSvc.EditEmail(ctx, id1, email1); err != ErrNotFound {
+	if err = userSvc.EditEmail(ctx, id1, email1, email1); err != ErrNotFound {
 		t.Fatalf("expected error: %v, got: %v", ErrNotFound, err)
 	}
 	if err = userSvc.EditName(ctx, id1, password1); err != ErrNotFound {
 		t.Fatalf("expected error: %v, got: %v", ErrNotFound, err)
 	}
+	if err = userSvc.EditLocale(ctx, id1, locale1); err != ErrNotFound {
+		t.Fatalf("expected error: %v, got: %v", ErrNotFound, err)
+	}
 	if err = userSvc.EditPassword(ctx, id1, password1); err != ErrNotFound {
 		t.Fatalf("expected error: %v, got: %v", ErrNotFound, err)
 	}
 }
+
+func TestUserServiceBackfill(t *testing.T) {
+	ctx := context.Background()
+	if _, err := NewUserService(ctx, svc, strings.ToLower); err != nil {
+		t.Fatal(err)
+	}
+	pool := svc.(*service).pool
+	var older, newer int64
+	if _, err := pool.Exec(ctx, "ALTER TABLE user_ ALTER COLUMN canonical_email DROP NOT NULL"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.QueryRow(ctx, "INSERT INTO user_ (email, name, password) VALUES ('a.b+x@backfill.com', 'a', 'p') RETURNING id").Scan(&older); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.QueryRow(ctx, "INSERT INTO user_ (email, name, password) VALUES ('AB@backfill.com', 'b', 'p') RETURNING id").Scan(&newer); err != nil {
+		t.Fatal(err)
+	}
+	normalize := func(email string) string {
+		local, domain, _ := strings.Cut(strings.ToLower(email), "@")
+		local, _, _ = strings.Cut(local, "+")
+		return strings.ReplaceAll(local, ".", "") + "@" + domain
+	}
+	_, err := NewUserService(ctx, svc, normalize)
+	if !errors.Is(err, ErrConflictingEmails) {
+		t.Fatalf("expected error: %v, got: %v", ErrConflictingEmails, err)
+	}
+	if expected := fmt.Sprintf("%d and %d (ab@backfill.com)", older, newer); !strings.Contains(err.Error(), expected) {
+		t.Fatalf("expected conflict: %s, got: %v", expected, err)
+	}
+	if _, err = pool.Exec(ctx, "UPDATE user_ SET email = 'c@backfill.com' WHERE id = $1", newer); err != nil {
+		t.Fatal(err)
+	}
+	userSvc, err := NewUserService(ctx, svc, normalize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := userSvc.GetByEmail(ctx, "ab@backfill.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Id != older {
+		t.Fatalf("expected id: %d, got: %d", older, user.Id)
+	}
+	user, err = userSvc.GetByEmail(ctx, "c@backfill.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Id != newer {
+		t.Fatalf("expected id: %d, got: %d", newer, user.Id)
+	}
+}
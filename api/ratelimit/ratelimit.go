@@ -1,102 +1,304 @@
 package ratelimit
 
 import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/time/rate"
 )
 
+const (
+	BackendMemory   = "memory"
+	BackendRedis    = "redis"
+	BackendPostgres = "postgres"
+)
+
+const (
+	AlgorithmTokenBucket   = "token-bucket"
+	AlgorithmSlidingLog    = "sliding-log"
+	AlgorithmSlidingWindow = "sliding-window"
+	AlgorithmQuota         = "quota"
+)
+
+var (
+	ErrUnknownBackend   = errors.New("ratelimit: unknown backend")
+	ErrUnknownAlgorithm = errors.New("ratelimit: unknown algorithm")
+	ErrInvalidWindow    = errors.New("ratelimit: window must be positive")
+)
+
+const maxInterval = 10 * 365 * 24 * time.Hour
+
 type Params struct {
-	Rate  float64 `yaml:"rate"`
-	Burst int     `yaml:"burst"`
+	Algorithm string        `yaml:"algorithm"`
+	Rate      float64       `yaml:"rate"`
+	Burst     int           `yaml:"burst"`
+	Window    time.Duration `yaml:"window"`
+}
+
+func (p Params) Validate() error {
+	switch p.Algorithm {
+	case "", AlgorithmTokenBucket:
+		return nil
+	case AlgorithmSlidingLog, AlgorithmSlidingWindow, AlgorithmQuota:
+		if p.Window <= 0 {
+			return ErrInvalidWindow
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownAlgorithm, p.Algorithm)
+	}
+}
+
+func (p Params) Interval() time.Duration {
+	if p.Rate <= 0 {
+		return maxInterval
+	}
+	return min(time.Duration(float64(time.Second)/p.Rate), maxInterval)
+}
+
+func (p Params) Tolerance() time.Duration {
+	return time.Duration(min(float64(p.Interval())*float64(p.Burst), float64(maxInterval)))
+}
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+func (p Params) Denied() Result {
+	return Result{RetryAfter: maxInterval}
+}
+
+func (p Params) GCRA(allowed bool, now, tat time.Time) Result {
+	interval := p.Interval()
+	tolerance := p.Tolerance()
+	r := Result{
+		Allowed:   allowed,
+		Limit:     p.Burst,
+		Remaining: min(max(int(now.Add(tolerance).Sub(tat)/interval), 0), p.Burst),
+		Reset:     max(tat.Sub(now), 0),
+	}
+	if !allowed {
+		r.RetryAfter = max(tat.Add(interval-tolerance).Sub(now), 0)
+	}
+	return r
+}
+
+type Stats struct {
+	Keys   int    `json:"keys"`
+	Allows uint64 `json:"allows"`
+	Denies uint64 `json:"denies"`
+}
+
+type Counter struct {
+	allows atomic.Uint64
+	denies atomic.Uint64
+}
+
+func (c *Counter) Count(result Result) Result {
+	if result.Allowed {
+		c.allows.Add(1)
+	} else {
+		c.denies.Add(1)
+	}
+	return result
+}
+
+func (c *Counter) Stats(keys int) Stats {
+	return Stats{keys, c.allows.Load(), c.denies.Load()}
+}
+
+const KeyCountsAge = 5 * time.Second
+
+var nameEscaper = strings.NewReplacer("%", "%25", ":", "%3A")
+
+func EscapeName(name string) string {
+	return nameEscaper.Replace(name)
+}
+
+type KeyCounts struct {
+	counts    map[string]int
+	expiresAt time.Time
+	mutex     sync.Mutex
+}
+
+func (c *KeyCounts) Get(ctx context.Context, count func(context.Context) (map[string]int, error)) (map[string]int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if time.Now().Before(c.expiresAt) {
+		return c.counts, nil
+	}
+	counts, err := count(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.counts = counts
+	c.expiresAt = time.Now().Add(KeyCountsAge)
+	return counts, nil
 }
 
 type Service interface {
-	NewLimiter(Params) Limiter
+	NewLimiter(string, Params) Limiter
+	Stats(context.Context) (map[string]Stats, error)
 	Close()
 }
 
-func NewService(cleanupInterval, idleTimeout time.Duration) Service {
-	s := &service{}
-	s.wg.Add(1)
-	go s.cleanup(cleanupInterval, idleTimeout)
-	return &service{
-		limiters: make([]*limiter, 0),
-		done:     make(chan struct{}),
+func NewService(cleanupInterval, idleTimeout time.Duration, maxKeys int) Service {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &service{
+		maxKeys: maxKeys,
+		cancel:  cancel,
 	}
+	s.wg.Add(1)
+	go s.cleanup(ctx, cleanupInterval, idleTimeout)
+	return s
 }
 
 type service struct {
 	limiters []*limiter
-	done     chan struct{}
+	maxKeys  int
+	cancel   context.CancelFunc
 	wg       sync.WaitGroup
 	mutex    sync.Mutex
 }
 
-func (s *service) NewLimiter(p Params) Limiter {
+func (s *service) NewLimiter(name string, p Params) Limiter {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	l := &limiter{
-		entries: make(map[string]*entry),
+		name:    name,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
 		params:  p,
+		maxKeys: s.maxKeys,
 	}
 	s.limiters = append(s.limiters, l)
 	return l
 }
 
+func (s *service) Stats(ctx context.Context) (map[string]Stats, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stats := make(map[string]Stats, len(s.limiters))
+	for _, l := range s.limiters {
+		l.mutex.Lock()
+		current := l.counter.Stats(l.order.Len())
+		l.mutex.Unlock()
+		total := stats[l.name]
+		stats[l.name] = Stats{total.Keys + current.Keys, total.Allows + current.Allows, total.Denies + current.Denies}
+	}
+	return stats, nil
+}
+
 func (s *service) Close() {
-	close(s.done)
+	s.cancel()
 	s.wg.Wait()
 }
 
-func (s *service) cleanup(interval, idleTimeout time.Duration) {
+func (s *service) cleanup(ctx context.Context, interval, idleTimeout time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(max(interval, time.Second))
+	defer ticker.Stop()
 	for {
 		select {
-		case <-s.done:
-			s.wg.Done()
-		default:
-			time.Sleep(interval)
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
 			s.mutex.Lock()
 			for _, l := range s.limiters {
-				l.mutex.Lock()
-				for key, e := range l.entries {
-					if time.Since(e.touchedAt) > idleTimeout {
-						delete(l.entries, key)
-					}
-				}
-				l.mutex.Unlock()
+				l.evict(now.Add(-idleTimeout))
 			}
 			s.mutex.Unlock()
 		}
-
 	}
 }
 
 type Limiter interface {
-	Allow(string) bool
+	Allow(context.Context, string) (Result, error)
 }
 
 type limiter struct {
-	entries map[string]*entry
+	name    string
+	entries map[string]*list.Element
+	order   *list.List
 	params  Params
+	maxKeys int
+	counter Counter
 	mutex   sync.Mutex
 }
 
 type entry struct {
+	key       string
 	limiter   *rate.Limiter
+	hits      []time.Time
+	window    Window
 	touchedAt time.Time
+	expiresAt time.Time
 }
 
-func (l *limiter) Allow(key string) bool {
+func (l *limiter) evict(before time.Time) {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
-	e, ok := l.entries[key]
-	if !ok {
-		limiter := rate.NewLimiter(rate.Limit(l.params.Rate), l.params.Burst)
-		l.entries[key] = &entry{limiter, time.Now()}
-		return limiter.Allow()
-	}
-	e.touchedAt = time.Now()
-	return e.limiter.Allow()
+	for e := l.order.Back(); e != nil && e.Value.(*entry).touchedAt.Before(before); {
+		prev := e.Prev()
+		if e.Value.(*entry).expiresAt.Before(before) {
+			l.order.Remove(e)
+			delete(l.entries, e.Value.(*entry).key)
+		}
+		e = prev
+	}
+}
+
+func (l *limiter) Allow(ctx context.Context, key string) (Result, error) {
+	if l.params.Burst < 1 {
+		return l.counter.Count(l.params.Denied()), nil
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := time.Now()
+	var e *entry
+	if element, ok := l.entries[key]; ok {
+		e = element.Value.(*entry)
+		l.order.MoveToFront(element)
+	} else {
+		e = &entry{key: key}
+		l.entries[key] = l.order.PushFront(e)
+		if l.maxKeys > 0 && l.order.Len() > l.maxKeys {
+			back := l.order.Back()
+			l.order.Remove(back)
+			delete(l.entries, back.Value.(*entry).key)
+		}
+	}
+	e.touchedAt = now
+	switch l.params.Algorithm {
+	case AlgorithmSlidingLog:
+		var allowed bool
+		e.hits, allowed = l.params.TakeLog(now, e.hits)
+		newest := e.hits[len(e.hits)-1]
+		e.expiresAt = newest.Add(l.params.Window)
+		return l.counter.Count(l.params.LogResult(allowed, now, len(e.hits), e.hits[0], newest)), nil
+	case AlgorithmSlidingWindow, AlgorithmQuota:
+		var allowed bool
+		e.window, allowed = l.params.TakeWindow(now, e.window)
+		result := l.params.WindowResult(allowed, now, e.window)
+		e.expiresAt = now.Add(result.Reset)
+		return l.counter.Count(result), nil
+	}
+	if e.limiter == nil {
+		e.limiter = rate.NewLimiter(rate.Limit(l.params.Rate), l.params.Burst)
+	}
+	allowed := e.limiter.AllowN(now, 1)
+	tokens := e.limiter.TokensAt(now)
+	interval := l.params.Interval()
+	tat := now.Add(time.Duration(min((float64(l.params.Burst)-tokens)*float64(interval), float64(maxInterval))))
+	return l.counter.Count(l.params.GCRA(allowed, now, tat)), nil
 }
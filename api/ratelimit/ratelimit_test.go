@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	s := NewService(time.Minute, time.Minute, 0)
+	defer s.Close()
+	l := s.NewLimiter("test", Params{Rate: 0.01, Burst: 2})
+	tests := []struct {
+		key       string
+		allowed   bool
+		remaining int
+	}{
+		{"x", true, 1},
+		{"x", true, 0},
+		{"x", false, 0},
+		{"y", true, 1},
+	}
+	for _, test := range tests {
+		result, err := l.Allow(context.Background(), test.key)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if result.Allowed != test.allowed {
+			t.Fatalf("expected allowed: %v, got: %v", test.allowed, result.Allowed)
+		}
+		if result.Remaining != test.remaining {
+			t.Fatalf("expected remaining: %d, got: %d", test.remaining, result.Remaining)
+		}
+		if result.Limit != 2 {
+			t.Fatalf("expected limit: %d, got: %d", 2, result.Limit)
+		}
+		if !result.Allowed && result.RetryAfter <= 0 {
+			t.Fatalf("expected positive retry after, got: %v", result.RetryAfter)
+		}
+	}
+	if result, _ := s.NewLimiter("denied", Params{}).Allow(context.Background(), "x"); result.Allowed {
+		t.Fatalf("expected allowed: %v, got: %v", false, result.Allowed)
+	}
+}
+
+func TestLimiterEviction(t *testing.T) {
+	s := NewService(time.Minute, time.Minute, 2)
+	defer s.Close()
+	l := s.NewLimiter("test", Params{Rate: 0.01, Burst: 1})
+	tests := []struct {
+		key     string
+		allowed bool
+	}{
+		{"x", true},
+		{"y", true},
+		{"x", false},
+		{"z", true},
+		{"y", true},
+		{"x", true},
+		{"x", false},
+	}
+	for _, test := range tests {
+		result, err := l.Allow(context.Background(), test.key)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if result.Allowed != test.allowed {
+			t.Fatalf("expected allowed: %v for key: %s, got: %v", test.allowed, test.key, result.Allowed)
+		}
+	}
+	l.(*limiter).evict(time.Now().Add(time.Minute))
+	stats, err := s.Stats(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	expected := Stats{Keys: 0, Allows: 5, Denies: 2}
+	if stats["test"] != expected {
+		t.Fatalf("expected stats: %+v, got: %+v", expected, stats["test"])
+	}
+}
+
+func TestLimiterWindowEviction(t *testing.T) {
+	s := NewService(time.Minute, time.Minute, 0)
+	defer s.Close()
+	for _, algorithm := range []string{AlgorithmSlidingLog, AlgorithmSlidingWindow, AlgorithmQuota} {
+		l := s.NewLimiter(algorithm, Params{Algorithm: algorithm, Burst: 1, Window: 24 * time.Hour})
+		if result, _ := l.Allow(context.Background(), "x"); !result.Allowed {
+			t.Fatalf("expected allowed: %v for %s, got: %v", true, algorithm, result.Allowed)
+		}
+		l.(*limiter).evict(time.Now().Add(time.Minute))
+		result, _ := l.Allow(context.Background(), "x")
+		if result.Allowed {
+			t.Fatalf("expected allowed: %v for %s, got: %v", false, algorithm, result.Allowed)
+		}
+		if result.RetryAfter <= 0 {
+			t.Fatalf("expected positive retry after for %s, got: %v", algorithm, result.RetryAfter)
+		}
+	}
+}
+
+func TestServiceClose(t *testing.T) {
+	s := NewService(time.Second, time.Minute, 0)
+	done := make(chan struct{})
+	go func() {
+		s.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("expected close to return")
+	}
+}
@@ -0,0 +1,237 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrMissingRedisAddr = errors.New("ratelimit: redis address is required")
+
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+var gcra = redis.NewScript(`
+local interval = tonumber(ARGV[1])
+local tolerance = tonumber(ARGV[2])
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+local tat = tonumber(redis.call("GET", KEYS[1]))
+if not tat or tat < now then
+	tat = now
+end
+local next = tat + interval
+if next - tolerance > now then
+	return {0, tat, now}
+end
+redis.call("SET", KEYS[1], next, "PX", math.ceil((next - now) / 1000))
+return {1, next, now}
+`)
+
+var slidingLog = redis.NewScript(`
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call("ZADD", KEYS[1], now, now .. ":" .. count)
+	count = count + 1
+	allowed = 1
+end
+local oldest = tonumber(redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")[2])
+local newest = tonumber(redis.call("ZRANGE", KEYS[1], -1, -1, "WITHSCORES")[2])
+redis.call("PEXPIRE", KEYS[1], math.ceil((newest + window - now) / 1000))
+return {allowed, now, count, oldest, newest}
+`)
+
+var slidingWindow = redis.NewScript(`
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local sliding = ARGV[3] == "1"
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+local start = now - now % window
+local values = redis.call("HMGET", KEYS[1], "start", "previous", "current")
+local stored = tonumber(values[1])
+local previous = 0
+local current = 0
+if stored == start then
+	previous = tonumber(values[2])
+	current = tonumber(values[3])
+elseif sliding and stored == start - window then
+	previous = tonumber(values[3])
+end
+local weight = 0
+if sliding then
+	weight = 1 - (now - start) / window
+end
+local allowed = 0
+if previous * weight + current + 1 <= limit then
+	current = current + 1
+	allowed = 1
+end
+redis.call("HSET", KEYS[1], "start", start, "previous", previous, "current", current)
+local ttl = start + window - now
+if sliding then
+	ttl = ttl + window
+end
+redis.call("PEXPIRE", KEYS[1], math.ceil(ttl / 1000))
+return {allowed, now, start, previous, current}
+`)
+
+type RedisConfig struct {
+	Addr     string `env:"ADDR" envDefault:""`
+	Password string `env:"PASSWORD" envDefault:""`
+	DB       int    `yaml:"db"`
+	Prefix   string `yaml:"prefix"`
+}
+
+func NewRedisService(ctx context.Context, cfg *RedisConfig) (Service, error) {
+	if cfg.Addr == "" {
+		return nil, ErrMissingRedisAddr
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &redisService{
+		client:   client,
+		prefix:   cfg.Prefix,
+		limiters: make(map[string]*redisLimiter),
+	}, nil
+}
+
+type redisService struct {
+	client   *redis.Client
+	prefix   string
+	limiters map[string]*redisLimiter
+	keys     KeyCounts
+	mutex    sync.Mutex
+}
+
+func (s *redisService) NewLimiter(name string, p Params) Limiter {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	l := &redisLimiter{client: s.client, prefix: s.prefix + EscapeName(name) + ":", params: p}
+	if existing, ok := s.limiters[name]; ok {
+		l.counter = existing.counter
+	} else {
+		l.counter = &Counter{}
+	}
+	s.limiters[name] = l
+	return l
+}
+
+func (s *redisService) Stats(ctx context.Context) (map[string]Stats, error) {
+	s.mutex.Lock()
+	limiters := make(map[string]*redisLimiter, len(s.limiters))
+	for name, l := range s.limiters {
+		limiters[name] = l
+	}
+	s.mutex.Unlock()
+	counts, err := s.keys.Get(ctx, s.countKeys)
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[string]Stats, len(limiters))
+	for name, l := range limiters {
+		stats[name] = l.counter.Stats(counts[EscapeName(name)])
+	}
+	return stats, nil
+}
+
+func (s *redisService) countKeys(ctx context.Context) (map[string]int, error) {
+	counts := make(map[string]int)
+	iter := s.client.Scan(ctx, 0, globEscaper.Replace(s.prefix)+"*", 1000).Iterator()
+	for iter.Next(ctx) {
+		if name, _, ok := strings.Cut(strings.TrimPrefix(iter.Val(), s.prefix), ":"); ok {
+			counts[name]++
+		}
+	}
+	return counts, iter.Err()
+}
+
+func (s *redisService) Close() {
+	s.client.Close()
+}
+
+type redisLimiter struct {
+	client  *redis.Client
+	prefix  string
+	params  Params
+	counter *Counter
+}
+
+func (l *redisLimiter) Allow(ctx context.Context, key string) (result Result, err error) {
+	if l.params.Burst < 1 {
+		return l.counter.Count(l.params.Denied()), nil
+	}
+	switch l.params.Algorithm {
+	case AlgorithmSlidingLog:
+		return l.allowLog(ctx, key)
+	case AlgorithmSlidingWindow, AlgorithmQuota:
+		return l.allowWindow(ctx, key)
+	}
+	values, err := gcra.Run(
+		ctx,
+		l.client,
+		[]string{l.prefix + key},
+		l.params.Interval().Microseconds(),
+		l.params.Tolerance().Microseconds(),
+	).Int64Slice()
+	if err != nil {
+		return
+	}
+	return l.counter.Count(l.params.GCRA(values[0] == 1, time.UnixMicro(values[2]), time.UnixMicro(values[1]))), nil
+}
+
+func (l *redisLimiter) allowLog(ctx context.Context, key string) (result Result, err error) {
+	values, err := slidingLog.Run(
+		ctx,
+		l.client,
+		[]string{l.prefix + key},
+		l.params.Window.Microseconds(),
+		l.params.Burst,
+	).Int64Slice()
+	if err != nil {
+		return
+	}
+	return l.counter.Count(l.params.LogResult(
+		values[0] == 1,
+		time.UnixMicro(values[1]),
+		int(values[2]),
+		time.UnixMicro(values[3]),
+		time.UnixMicro(values[4]),
+	)), nil
+}
+
+func (l *redisLimiter) allowWindow(ctx context.Context, key string) (result Result, err error) {
+	sliding := 0
+	if l.params.Algorithm == AlgorithmSlidingWindow {
+		sliding = 1
+	}
+	values, err := slidingWindow.Run(
+		ctx,
+		l.client,
+		[]string{l.prefix + key},
+		l.params.Window.Microseconds(),
+		l.params.Burst,
+		sliding,
+	).Int64Slice()
+	if err != nil {
+		return
+	}
+	w := Window{time.UnixMicro(values[2]), int(values[3]), int(values[4])}
+	return l.counter.Count(l.params.WindowResult(values[0] == 1, time.UnixMicro(values[1]), w)), nil
+}
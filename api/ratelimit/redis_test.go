@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestRedisLimiter(t *testing.T) {
+	m := miniredis.RunT(t)
+	now := time.Now()
+	m.SetTime(now)
+	s, err := NewRedisService(context.Background(), &RedisConfig{Addr: m.Addr(), Prefix: "test:"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer s.Close()
+	limiters := map[string]Limiter{
+		"a": s.NewLimiter("a", Params{Rate: 1, Burst: 2}),
+		"b": s.NewLimiter("b", Params{Rate: 1, Burst: 2}),
+		"c": s.NewLimiter("c", Params{Burst: 0}),
+	}
+	tests := []struct {
+		limiter  string
+		key      string
+		elapsed  time.Duration
+		expected Result
+	}{
+		{"a", "x", 0, Result{true, 2, 1, time.Second, 0}},
+		{"a", "x", 0, Result{true, 2, 0, 2 * time.Second, 0}},
+		{"a", "x", 0, Result{false, 2, 0, 2 * time.Second, time.Second}},
+		{"a", "y", 0, Result{true, 2, 1, time.Second, 0}},
+		{"b", "x", 0, Result{true, 2, 1, time.Second, 0}},
+		{"c", "x", 0, Result{false, 0, 0, 0, maxInterval}},
+		{"a", "x", 500 * time.Millisecond, Result{false, 2, 0, 1500 * time.Millisecond, 500 * time.Millisecond}},
+		{"a", "x", 500 * time.Millisecond, Result{true, 2, 0, 2 * time.Second, 0}},
+		{"a", "x", 0, Result{false, 2, 0, 2 * time.Second, time.Second}},
+		{"a", "x", 5 * time.Second, Result{true, 2, 1, time.Second, 0}},
+		{"a", "x", 0, Result{true, 2, 0, 2 * time.Second, 0}},
+		{"a", "x", 0, Result{false, 2, 0, 2 * time.Second, time.Second}},
+	}
+	for _, test := range tests {
+		now = now.Add(test.elapsed)
+		m.SetTime(now)
+		result, err := limiters[test.limiter].Allow(context.Background(), test.key)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if result != test.expected {
+			t.Fatalf("expected result: %+v, got: %+v", test.expected, result)
+		}
+	}
+	if !m.Exists("test:a:x") {
+		t.Fatalf("expected key: %s", "test:a:x")
+	}
+	stats, err := s.Stats(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	expected := map[string]Stats{
+		"a": {Keys: 2, Allows: 6, Denies: 4},
+		"b": {Keys: 1, Allows: 1, Denies: 0},
+		"c": {Keys: 0, Allows: 0, Denies: 1},
+	}
+	for name, e := range expected {
+		if stats[name] != e {
+			t.Fatalf("expected stats: %+v for limiter: %s, got: %+v", e, name, stats[name])
+		}
+	}
+}
+
+func TestRedisWindowLimiter(t *testing.T) {
+	m := miniredis.RunT(t)
+	s, err := NewRedisService(context.Background(), &RedisConfig{Addr: m.Addr(), Prefix: "test:"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer s.Close()
+	for _, test := range windowTests {
+		l := s.NewLimiter(test.params.Algorithm, test.params)
+		for _, step := range test.steps {
+			m.SetTime(windowBase.Add(step.elapsed))
+			result, err := l.Allow(context.Background(), "x")
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if result != step.expected {
+				t.Fatalf("expected result: %+v for %s at %v, got: %+v", step.expected, test.params.Algorithm, step.elapsed, result)
+			}
+		}
+	}
+}
+
+func TestNewRedisService(t *testing.T) {
+	if _, err := NewRedisService(context.Background(), &RedisConfig{}); err != ErrMissingRedisAddr {
+		t.Fatalf("expected error: %v, got: %v", ErrMissingRedisAddr, err)
+	}
+}
+
+func TestRedisStatsNames(t *testing.T) {
+	m := miniredis.RunT(t)
+	s, err := NewRedisService(context.Background(), &RedisConfig{Addr: m.Addr(), Prefix: "test:"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer s.Close()
+	for _, name := range []string{"user.session", "user.session:x", "user.session%3Ax"} {
+		if _, err = s.NewLimiter(name, Params{Rate: 1, Burst: 1}).Allow(context.Background(), "x"); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	stats, err := s.Stats(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(stats) != 3 {
+		t.Fatalf("expected limiters: 3, got: %d", len(stats))
+	}
+	for name, e := range stats {
+		if e.Keys != 1 {
+			t.Fatalf("expected keys: 1 for limiter: %s, got: %d", name, e.Keys)
+		}
+	}
+	if _, err = s.NewLimiter("user.session", Params{Rate: 1, Burst: 1}).Allow(context.Background(), "y"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if stats, err = s.Stats(context.Background()); err != nil || stats["user.session"].Keys != 1 {
+		t.Fatalf("expected cached keys: 1, got: %+v, %v", stats["user.session"], err)
+	}
+}
@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+type Window struct {
+	Start    time.Time
+	Previous int
+	Current  int
+}
+
+func (p Params) TakeLog(now time.Time, hits []time.Time) ([]time.Time, bool) {
+	i := 0
+	for i < len(hits) && !hits[i].After(now.Add(-p.Window)) {
+		i++
+	}
+	hits = hits[i:]
+	if len(hits) >= p.Burst {
+		return hits, false
+	}
+	return append(hits, now), true
+}
+
+func (p Params) LogResult(allowed bool, now time.Time, count int, oldest, newest time.Time) Result {
+	r := Result{
+		Allowed:   allowed,
+		Limit:     p.Burst,
+		Remaining: max(p.Burst-count, 0),
+	}
+	if count > 0 {
+		r.Reset = max(newest.Add(p.Window).Sub(now), 0)
+	}
+	if !allowed {
+		r.RetryAfter = max(oldest.Add(p.Window).Sub(now), 0)
+	}
+	return r
+}
+
+func (p Params) WindowStart(now time.Time) time.Time {
+	window := p.Window.Microseconds()
+	return time.UnixMicro(now.UnixMicro() / window * window)
+}
+
+func (p Params) weight(now time.Time, w Window) float64 {
+	if p.Algorithm != AlgorithmSlidingWindow {
+		return 0
+	}
+	return 1 - float64(now.Sub(w.Start))/float64(p.Window)
+}
+
+func (p Params) estimate(now time.Time, w Window) float64 {
+	return float64(w.Previous)*p.weight(now, w) + float64(w.Current)
+}
+
+func (p Params) TakeWindow(now time.Time, w Window) (Window, bool) {
+	start := p.WindowStart(now)
+	switch {
+	case w.Start.Equal(start):
+	case p.Algorithm == AlgorithmSlidingWindow && w.Start.Add(p.Window).Equal(start):
+		w = Window{Start: start, Previous: w.Current}
+	default:
+		w = Window{Start: start}
+	}
+	if p.estimate(now, w)+1 > float64(p.Burst) {
+		return w, false
+	}
+	w.Current++
+	return w, true
+}
+
+func (p Params) WindowResult(allowed bool, now time.Time, w Window) Result {
+	end := w.Start.Add(p.Window)
+	r := Result{
+		Allowed:   allowed,
+		Limit:     p.Burst,
+		Remaining: min(max(p.Burst-int(math.Ceil(p.estimate(now, w))), 0), p.Burst),
+		Reset:     end.Sub(now),
+	}
+	if p.Algorithm == AlgorithmSlidingWindow && w.Current > 0 {
+		r.Reset += p.Window
+	}
+	if allowed {
+		return r
+	}
+	r.RetryAfter = end.Sub(now)
+	if p.Algorithm != AlgorithmSlidingWindow {
+		return r
+	}
+	if w.Current < p.Burst {
+		elapsed := 1 - float64(p.Burst-1-w.Current)/float64(w.Previous)
+		r.RetryAfter = max(w.Start.Add(time.Duration(elapsed*float64(p.Window))).Sub(now), 0)
+	} else {
+		elapsed := 1 - float64(p.Burst-1)/float64(w.Current)
+		r.RetryAfter += time.Duration(elapsed * float64(p.Window))
+	}
+	return r
+}
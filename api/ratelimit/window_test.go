@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var windowBase = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type windowStep struct {
+	elapsed  time.Duration
+	expected Result
+}
+
+var windowTests = []struct {
+	params Params
+	steps  []windowStep
+}{
+	{
+		Params{Algorithm: AlgorithmSlidingLog, Burst: 2, Window: time.Hour},
+		[]windowStep{
+			{0, Result{true, 2, 1, time.Hour, 0}},
+			{10 * time.Minute, Result{true, 2, 0, time.Hour, 0}},
+			{20 * time.Minute, Result{false, 2, 0, 50 * time.Minute, 40 * time.Minute}},
+			{60 * time.Minute, Result{true, 2, 0, time.Hour, 0}},
+			{65 * time.Minute, Result{false, 2, 0, 55 * time.Minute, 5 * time.Minute}},
+		},
+	},
+	{
+		Params{Algorithm: AlgorithmSlidingWindow, Burst: 2, Window: time.Hour},
+		[]windowStep{
+			{0, Result{true, 2, 1, 2 * time.Hour, 0}},
+			{30 * time.Minute, Result{true, 2, 0, 90 * time.Minute, 0}},
+			{40 * time.Minute, Result{false, 2, 0, 80 * time.Minute, 50 * time.Minute}},
+			{90 * time.Minute, Result{true, 2, 0, 90 * time.Minute, 0}},
+			{100 * time.Minute, Result{false, 2, 0, 80 * time.Minute, 20 * time.Minute}},
+		},
+	},
+	{
+		Params{Algorithm: AlgorithmQuota, Burst: 2, Window: time.Hour},
+		[]windowStep{
+			{10 * time.Minute, Result{true, 2, 1, 50 * time.Minute, 0}},
+			{20 * time.Minute, Result{true, 2, 0, 40 * time.Minute, 0}},
+			{30 * time.Minute, Result{false, 2, 0, 30 * time.Minute, 30 * time.Minute}},
+			{60 * time.Minute, Result{true, 2, 1, time.Hour, 0}},
+		},
+	},
+}
+
+func TestWindowAlgorithms(t *testing.T) {
+	for _, test := range windowTests {
+		var hits []time.Time
+		var w Window
+		for _, step := range test.steps {
+			now := windowBase.Add(step.elapsed)
+			var result Result
+			if test.params.Algorithm == AlgorithmSlidingLog {
+				var allowed bool
+				hits, allowed = test.params.TakeLog(now, hits)
+				result = test.params.LogResult(allowed, now, len(hits), hits[0], hits[len(hits)-1])
+			} else {
+				var allowed bool
+				w, allowed = test.params.TakeWindow(now, w)
+				result = test.params.WindowResult(allowed, now, w)
+			}
+			if result != step.expected {
+				t.Fatalf("expected result: %+v for %s at %v, got: %+v", step.expected, test.params.Algorithm, step.elapsed, result)
+			}
+		}
+	}
+}
+
+func TestParamsValidate(t *testing.T) {
+	tests := []struct {
+		params   Params
+		expected error
+	}{
+		{Params{Rate: 1, Burst: 1}, nil},
+		{Params{Algorithm: AlgorithmTokenBucket}, nil},
+		{Params{Algorithm: AlgorithmQuota, Burst: 1, Window: time.Hour}, nil},
+		{Params{Algorithm: AlgorithmSlidingLog, Burst: 1}, ErrInvalidWindow},
+		{Params{Algorithm: AlgorithmSlidingWindow, Window: -time.Second}, ErrInvalidWindow},
+		{Params{Algorithm: "leaky"}, ErrUnknownAlgorithm},
+	}
+	for _, test := range tests {
+		if err := test.params.Validate(); !errors.Is(err, test.expected) {
+			t.Fatalf("expected error: %v, got: %v", test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileService(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	fileSvc, err := NewBackend(&Config{Backend: BackendFile, Dir: dir, From: from.Address, FromName: from.Name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileSvc.Close()
+	if err = fileSvc.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if err = fileSvc.Send(context.Background(), to, tmpl, data); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || !strings.HasSuffix(entries[0].Name(), ".eml") {
+		t.Fatalf("expected one eml file, got: %v", entries)
+	}
+	f, err := os.Open(filepath.Join(dir, entries[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	msg, err := mail.ReadMessage(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := msg.Header.Get("Subject"); got != tmpl.Subject {
+		t.Fatalf("expected subject: %s, got: %s", tmpl.Subject, got)
+	}
+	if _, err = NewFileService(&Config{From: from.Address}); err != ErrMissingDir {
+		t.Fatalf("expected error: %v, got: %v", ErrMissingDir, err)
+	}
+}
+
+func TestMemoryService(t *testing.T) {
+	memorySvc, err := NewMemoryService(&Config{From: from.Address, FromName: from.Name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := mail.Address{Name: "Alice", Address: "alice@example.com"}
+	for _, address := range []mail.Address{to, other, to} {
+		if err = memorySvc.Send(context.Background(), address, tmpl, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	messages := memorySvc.Messages()
+	if len(messages) != 3 {
+		t.Fatalf("expected messages: %d, got: %d", 3, len(messages))
+	}
+	last, ok := memorySvc.Last(to.Address)
+	if !ok || last.MessageID != messages[2].MessageID {
+		t.Fatalf("expected last message: %s, got: %s", messages[2].MessageID, last.MessageID)
+	}
+	if !strings.Contains(last.Text, "Hello, Bob!") || len(last.Raw) == 0 {
+		t.Fatalf("unexpected message: %v", last.Message)
+	}
+	memorySvc.Reset()
+	if _, ok = memorySvc.Last(to.Address); ok {
+		t.Fatal("expected no messages after reset")
+	}
+}
+
+func TestNewBackend(t *testing.T) {
+	if _, err := NewBackend(&Config{Backend: "pigeon"}); !errors.Is(err, ErrUnknownBackend) {
+		t.Fatalf("expected error: %v, got: %v", ErrUnknownBackend, err)
+	}
+	if _, err := NewBackend(&Config{From: from.Address}); err != ErrMissingAddress {
+		t.Fatalf("expected error: %v, got: %v", ErrMissingAddress, err)
+	}
+}
@@ -0,0 +1,98 @@
+package smtp
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+
+	"github.com/emersion/go-msgauth/dkim"
+)
+
+var (
+	ErrInvalidPrivateKey  = errors.New("smtp: invalid dkim private key")
+	ErrIncompleteDKIM     = errors.New("smtp: dkim requires domain, selector and private key")
+	DefaultDKIMHeaderKeys = []string{
+		"From",
+		"To",
+		"Subject",
+		"Date",
+		"Message-ID",
+		"MIME-Version",
+		"Content-Type",
+		"Content-Transfer-Encoding",
+	}
+)
+
+type PrivateKey struct {
+	crypto.Signer
+}
+
+func (k *PrivateKey) UnmarshalText(src []byte) error {
+	if len(src) == 0 {
+		k.Signer = nil
+		return nil
+	}
+	block, _ := pem.Decode(src)
+	if block == nil {
+		return ErrInvalidPrivateKey
+	}
+	var (
+		key any
+		err error
+	)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return ErrInvalidPrivateKey
+	}
+	if err != nil {
+		return errors.Join(ErrInvalidPrivateKey, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return ErrInvalidPrivateKey
+	}
+	k.Signer = signer
+	return nil
+}
+
+type DKIMConfig struct {
+	Domain     string     `yaml:"domain"`
+	Selector   string     `yaml:"selector"`
+	PrivateKey PrivateKey `yaml:"-" env:"PRIVATE_KEY_FILE,file" envDefault:""`
+}
+
+type Signer struct {
+	opts *dkim.SignOptions
+}
+
+func NewSigner(cfg *DKIMConfig) (*Signer, error) {
+	if cfg.Domain == "" && cfg.Selector == "" && cfg.PrivateKey.Signer == nil {
+		return &Signer{}, nil
+	}
+	if cfg.Domain == "" || cfg.Selector == "" || cfg.PrivateKey.Signer == nil {
+		return nil, ErrIncompleteDKIM
+	}
+	return &Signer{&dkim.SignOptions{
+		Domain:                 cfg.Domain,
+		Selector:               cfg.Selector,
+		Signer:                 cfg.PrivateKey.Signer,
+		Hash:                   crypto.SHA256,
+		HeaderCanonicalization: dkim.CanonicalizationRelaxed,
+		BodyCanonicalization:   dkim.CanonicalizationRelaxed,
+		HeaderKeys:             DefaultDKIMHeaderKeys,
+	}}, nil
+}
+
+func (s *Signer) Sign(w io.Writer, r io.Reader) error {
+	if s.opts == nil {
+		_, err := io.Copy(w, r)
+		return err
+	}
+	return dkim.Sign(w, r, s.opts)
+}
@@ -0,0 +1,120 @@
+package smtp
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-msgauth/dkim"
+)
+
+func TestSigner(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed25519Pub, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(ed25519Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPub, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		block  *pem.Block
+		record string
+	}{
+		{
+			&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)},
+			"v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(rsaPub),
+		},
+		{
+			&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8},
+			"v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(ed25519Pub),
+		},
+	}
+	for _, test := range tests {
+		var key PrivateKey
+		if err = key.UnmarshalText(pem.EncodeToMemory(test.block)); err != nil {
+			t.Fatal(err)
+		}
+		signer, err := NewSigner(&DKIMConfig{
+			Domain:     "example.com",
+			Selector:   "mail",
+			PrivateKey: key,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf, signed bytes.Buffer
+		if err = Write(&buf, &Message{
+			From:      from,
+			To:        to,
+			Subject:   "Greeting",
+			Date:      time.Now(),
+			MessageID: "<1@example.com>",
+			Text:      "Hello, Bob!",
+			HTML:      "<h1>Hello, Bob!</h1>",
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err = signer.Sign(&signed, &buf); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(signed.String(), "DKIM-Signature: ") {
+			t.Fatalf("expected DKIM-Signature header, got: %s", signed.String())
+		}
+		verifications, err := dkim.VerifyWithOptions(&signed, &dkim.VerifyOptions{
+			LookupTXT: func(domain string) ([]string, error) {
+				if domain != "mail._domainkey.example.com" {
+					return nil, fmt.Errorf("unexpected domain: %s", domain)
+				}
+				return []string{test.record}, nil
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(verifications) != 1 || verifications[0].Err != nil {
+			t.Fatalf("expected valid signature, got: %v", verifications)
+		}
+	}
+}
+
+func TestSignerDisabled(t *testing.T) {
+	signer, err := NewSigner(&DKIMConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var signed bytes.Buffer
+	if err = signer.Sign(&signed, strings.NewReader("Subject: Hi\r\n\r\nHi\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if signed.String() != "Subject: Hi\r\n\r\nHi\r\n" {
+		t.Fatalf("expected unsigned message, got: %q", signed.String())
+	}
+}
+
+func TestSignerErrors(t *testing.T) {
+	var key PrivateKey
+	if err := key.UnmarshalText([]byte("not a key")); !errors.Is(err, ErrInvalidPrivateKey) {
+		t.Fatalf("expected error: %v, got: %v", ErrInvalidPrivateKey, err)
+	}
+	if _, err := NewSigner(&DKIMConfig{Domain: "example.com"}); err != ErrIncompleteDKIM {
+		t.Fatalf("expected error: %v, got: %v", ErrIncompleteDKIM, err)
+	}
+}
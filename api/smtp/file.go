@@ -0,0 +1,62 @@
+package smtp
+
+import (
+	"context"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func NewFileService(cfg *Config) (Service, error) {
+	if cfg.Dir == "" {
+		return nil, ErrMissingDir
+	}
+	renderer, err := newRenderer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &fileService{renderer, cfg.Dir}, nil
+}
+
+type fileService struct {
+	*renderer
+	dir string
+}
+
+func (s *fileService) Ping() error {
+	f, err := os.CreateTemp(s.dir, ".ping-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+func (s *fileService) Send(ctx context.Context, to mail.Address, tmpl *Template, data any) error {
+	m, raw, err := s.render(to, tmpl, data)
+	if err != nil {
+		return err
+	}
+	name := m.Date.UTC().Format("20060102T150405.000000000Z") + "-" +
+		strings.Trim(m.MessageID[:strings.IndexByte(m.MessageID, '@')], "<") + ".eml"
+	f, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(raw); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(s.dir, name))
+}
+
+func (s *fileService) Close() {}
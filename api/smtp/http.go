@@ -0,0 +1,134 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+var (
+	ErrMissingEndpoint = errors.New("smtp: http endpoint must be an absolute url")
+	ErrInvalidPayload  = errors.New("smtp: http payload is not valid json")
+)
+
+type HTTPConfig struct {
+	Endpoint   string            `yaml:"endpoint"`
+	AuthHeader string            `yaml:"authHeader"`
+	AuthValue  string            `env:"AUTH_VALUE" envDefault:""`
+	Headers    map[string]string `yaml:"headers"`
+	Payload    string            `yaml:"payload"`
+	Timeout    time.Duration     `yaml:"timeout"`
+}
+
+type HTTPPayload struct {
+	Message
+	Raw string
+}
+
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("smtp: http provider responded with %d: %s", e.StatusCode, e.Body)
+}
+
+func NewHTTPTransport(cfg *HTTPConfig) (Transport, error) {
+	if u, err := url.Parse(cfg.Endpoint); err != nil || !u.IsAbs() || u.Host == "" {
+		return nil, ErrMissingEndpoint
+	}
+	payload, err := texttemplate.New("payload").Funcs(map[string]any{
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(cfg.Payload)
+	if err != nil {
+		return nil, err
+	}
+	t := &httpTransport{
+		endpoint:   cfg.Endpoint,
+		authHeader: cfg.AuthHeader,
+		authValue:  cfg.AuthValue,
+		headers:    cfg.Headers,
+		payload:    payload,
+		client:     &http.Client{Timeout: cfg.Timeout},
+	}
+	if _, err = t.encode(&HTTPPayload{}); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+type httpTransport struct {
+	endpoint   string
+	authHeader string
+	authValue  string
+	headers    map[string]string
+	payload    *texttemplate.Template
+	client     *http.Client
+}
+
+func (t *httpTransport) encode(p *HTTPPayload) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := t.payload.Execute(&buf, p); err != nil {
+		return nil, err
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, ErrInvalidPayload
+	}
+	return buf.Bytes(), nil
+}
+
+func (t *httpTransport) Ping() error {
+	return nil
+}
+
+func (t *httpTransport) Send(ctx context.Context, from, to string, message []byte) error {
+	m, err := ReadMessage(bytes.NewReader(message))
+	if err != nil {
+		return err
+	}
+	m.From.Address = from
+	m.To.Address = to
+	body, err := t.encode(&HTTPPayload{*m, base64.StdEncoding.EncodeToString(message)})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	if t.authHeader != "" {
+		req.Header.Set(t.authHeader, t.authValue)
+	}
+	res, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		b, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return &HTTPError{res.StatusCode, strings.TrimSpace(string(b))}
+	}
+	_, err = io.Copy(io.Discard, res.Body)
+	return err
+}
+
+func (t *httpTransport) Close() {
+	t.client.CloseIdleConnections()
+}
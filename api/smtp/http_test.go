@@ -0,0 +1,133 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const httpPayload = `{
+	"from": {"email": {{json .From.Address}}, "name": {{json .From.Name}}},
+	"to": [{"email": {{json .To.Address}}}],
+	"subject": {{json .Subject}},
+	"text": {{json .Text}},
+	"html": {{json .HTML}},
+	"raw": {{json .Raw}}
+}`
+
+func TestHTTPTransport(t *testing.T) {
+	type body struct {
+		From struct {
+			Email string `json:"email"`
+			Name  string `json:"name"`
+		} `json:"from"`
+		To []struct {
+			Email string `json:"email"`
+		} `json:"to"`
+		Subject string `json:"subject"`
+		Text    string `json:"text"`
+		HTML    string `json:"html"`
+		Raw     string `json:"raw"`
+	}
+	requests := make(chan body, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("X-Tag") != "auth" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		var b body
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if b.To[0].Email == "bounce@example.com" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			io.WriteString(w, "invalid recipient")
+			return
+		}
+		requests <- b
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	transport, err := NewHTTPTransport(&HTTPConfig{
+		Endpoint:   server.URL,
+		AuthHeader: "Authorization",
+		AuthValue:  "Bearer secret",
+		Headers:    map[string]string{"X-Tag": "auth"},
+		Payload:    httpPayload,
+		Timeout:    time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+	text, html, err := tmpl.Render(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw bytes.Buffer
+	if err = Write(&raw, &Message{
+		From:      from,
+		To:        to,
+		Subject:   "Zażółć",
+		Date:      time.Now(),
+		MessageID: "<1@example.com>",
+		Text:      text,
+		HTML:      html,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err = transport.Send(context.Background(), from.Address, to.Address, raw.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	b := <-requests
+	if b.From.Email != from.Address || b.From.Name != from.Name || len(b.To) != 1 || b.To[0].Email != to.Address {
+		t.Fatalf("unexpected addresses: %v", b)
+	}
+	if b.Subject != "Zażółć" {
+		t.Fatalf("expected subject: %s, got: %s", "Zażółć", b.Subject)
+	}
+	if !strings.Contains(b.Text, "Hello, Bob!") || !strings.Contains(b.HTML, "<h1>Hello, Bob!</h1>") {
+		t.Fatalf("unexpected content: %q %q", b.Text, b.HTML)
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(b.Raw); err != nil || !bytes.Equal(decoded, raw.Bytes()) {
+		t.Fatalf("expected raw message, got: %v", err)
+	}
+	err = transport.Send(context.Background(), from.Address, "bounce@example.com", raw.Bytes())
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusUnprocessableEntity || httpErr.Body != "invalid recipient" {
+		t.Fatalf("expected http error: %d, got: %v", http.StatusUnprocessableEntity, err)
+	}
+}
+
+func TestNewHTTPTransport(t *testing.T) {
+	tests := []struct {
+		cfg *HTTPConfig
+		err error
+	}{
+		{&HTTPConfig{Payload: httpPayload}, ErrMissingEndpoint},
+		{&HTTPConfig{Endpoint: "/send", Payload: httpPayload}, ErrMissingEndpoint},
+		{&HTTPConfig{Endpoint: "https://api.example.com/send", Payload: `{"to": {{.To.Address}}}`}, ErrInvalidPayload},
+		{&HTTPConfig{Endpoint: "https://api.example.com/send"}, ErrInvalidPayload},
+	}
+	for _, test := range tests {
+		if _, err := NewHTTPTransport(test.cfg); err != test.err {
+			t.Fatalf("expected error: %v, got: %v", test.err, err)
+		}
+	}
+	if _, err := NewTransport(&Config{Transport: "pigeon"}); !errors.Is(err, ErrUnknownTransport) {
+		t.Fatalf("expected error: %v, got: %v", ErrUnknownTransport, err)
+	}
+}
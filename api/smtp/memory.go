@@ -0,0 +1,68 @@
+package smtp
+
+import (
+	"context"
+	"net/mail"
+	"sync"
+)
+
+type SentMessage struct {
+	Message
+	Raw []byte
+}
+
+type MemoryService struct {
+	*renderer
+	messages []SentMessage
+	mutex    sync.Mutex
+}
+
+func NewMemoryService(cfg *Config) (*MemoryService, error) {
+	renderer, err := newRenderer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &MemoryService{renderer: renderer}, nil
+}
+
+func (s *MemoryService) Ping() error {
+	return nil
+}
+
+func (s *MemoryService) Send(ctx context.Context, to mail.Address, tmpl *Template, data any) error {
+	m, raw, err := s.render(to, tmpl, data)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.messages = append(s.messages, SentMessage{*m, raw})
+	return nil
+}
+
+func (s *MemoryService) Messages() []SentMessage {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	messages := make([]SentMessage, len(s.messages))
+	copy(messages, s.messages)
+	return messages
+}
+
+func (s *MemoryService) Last(to string) (SentMessage, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		if s.messages[i].To.Address == to {
+			return s.messages[i], true
+		}
+	}
+	return SentMessage{}, false
+}
+
+func (s *MemoryService) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.messages = nil
+}
+
+func (s *MemoryService) Close() {}
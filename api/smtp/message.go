@@ -0,0 +1,242 @@
+package smtp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+type Message struct {
+	From      mail.Address
+	To        mail.Address
+	Subject   string
+	Date      time.Time
+	MessageID string
+	Text      string
+	HTML      string
+}
+
+type Template struct {
+	Subject string
+	Text    *texttemplate.Template
+	HTML    *htmltemplate.Template
+}
+
+func (t *Template) Render(data any) (text, html string, err error) {
+	var buf strings.Builder
+	if t.Text != nil {
+		if err = t.Text.Execute(&buf, data); err != nil {
+			return
+		}
+		text = buf.String()
+		buf.Reset()
+	}
+	if t.HTML != nil {
+		if err = t.HTML.Execute(&buf, data); err != nil {
+			return
+		}
+		html = buf.String()
+	}
+	return
+}
+
+func NewMessageID(domain string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), domain), nil
+}
+
+func writePart(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qp, body); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func Write(w io.Writer, m *Message) error {
+	type part struct {
+		contentType string
+		body        string
+	}
+	var parts []part
+	if m.Text != "" {
+		parts = append(parts, part{`text/plain; charset="utf-8"`, m.Text})
+	}
+	if m.HTML != "" {
+		parts = append(parts, part{`text/html; charset="utf-8"`, m.HTML})
+	}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	contentType := fmt.Sprintf("multipart/alternative; boundary=%q", mw.Boundary())
+	if len(parts) == 1 {
+		contentType = parts[0].contentType
+		if err := writePart(&body, parts[0].body); err != nil {
+			return err
+		}
+	} else {
+		for _, p := range parts {
+			pw, err := mw.CreatePart(textproto.MIMEHeader{
+				"Content-Type":              {p.contentType},
+				"Content-Transfer-Encoding": {"quoted-printable"},
+			})
+			if err != nil {
+				return err
+			}
+			if err = writePart(pw, p.body); err != nil {
+				return err
+			}
+		}
+		if err := mw.Close(); err != nil {
+			return err
+		}
+	}
+	type header struct {
+		key   string
+		value string
+	}
+	headers := []header{
+		{"From", m.From.String()},
+		{"To", m.To.String()},
+		{"Subject", mime.QEncoding.Encode("utf-8", m.Subject)},
+		{"Date", m.Date.Format(time.RFC1123Z)},
+		{"Message-ID", m.MessageID},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", contentType},
+	}
+	if len(parts) == 1 {
+		headers = append(headers, header{"Content-Transfer-Encoding", "quoted-printable"})
+	}
+	for _, header := range headers {
+		if _, err := fmt.Fprintf(w, "%s: %s\r\n", header.key, header.value); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprint(w, "\r\n"); err != nil {
+		return err
+	}
+	_, err := body.WriteTo(w)
+	return err
+}
+
+func readPart(m *Message, mediaType, encoding string, r io.Reader) error {
+	if strings.EqualFold(encoding, "quoted-printable") {
+		r = quotedprintable.NewReader(r)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	switch mediaType {
+	case "text/plain":
+		m.Text = string(b)
+	case "text/html":
+		m.HTML = string(b)
+	}
+	return nil
+}
+
+func ReadMessage(r io.Reader) (*Message, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	m := &Message{MessageID: msg.Header.Get("Message-Id")}
+	var dec mime.WordDecoder
+	if m.Subject, err = dec.DecodeHeader(msg.Header.Get("Subject")); err != nil {
+		return nil, err
+	}
+	if m.Date, err = msg.Header.Date(); err != nil {
+		return nil, err
+	}
+	for key, address := range map[string]*mail.Address{"From": &m.From, "To": &m.To} {
+		list, err := msg.Header.AddressList(key)
+		if err != nil {
+			return nil, err
+		}
+		*address = *list[0]
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return m, readPart(m, mediaType, msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return m, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		mediaType, _, err := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		if err != nil {
+			return nil, err
+		}
+		if err = readPart(m, mediaType, p.Header.Get("Content-Transfer-Encoding"), p); err != nil {
+			return nil, err
+		}
+	}
+}
+
+type renderer struct {
+	from   mail.Address
+	domain string
+	signer *Signer
+}
+
+func newRenderer(cfg *Config) (*renderer, error) {
+	signer, err := NewSigner(&cfg.DKIM)
+	if err != nil {
+		return nil, err
+	}
+	return &renderer{
+		from:   mail.Address{Name: cfg.FromName, Address: cfg.From},
+		domain: cfg.From[strings.LastIndexByte(cfg.From, '@')+1:],
+		signer: signer,
+	}, nil
+}
+
+func (r *renderer) render(to mail.Address, tmpl *Template, data any) (*Message, []byte, error) {
+	text, html, err := tmpl.Render(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	id, err := NewMessageID(r.domain)
+	if err != nil {
+		return nil, nil, err
+	}
+	m := &Message{
+		From:      r.from,
+		To:        to,
+		Subject:   tmpl.Subject,
+		Date:      time.Now(),
+		MessageID: id,
+		Text:      text,
+		HTML:      html,
+	}
+	var buf, signed bytes.Buffer
+	if err = Write(&buf, m); err != nil {
+		return nil, nil, err
+	}
+	if err = r.signer.Sign(&signed, &buf); err != nil {
+		return nil, nil, err
+	}
+	return m, signed.Bytes(), nil
+}
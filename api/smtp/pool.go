@@ -0,0 +1,155 @@
+package smtp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-sasl"
+	"github.com/emersion/go-smtp"
+	"golang.org/x/time/rate"
+)
+
+type PoolConfig struct {
+	Size          int           `yaml:"size"`
+	MaxMessages   int           `yaml:"maxMessages"`
+	IdleTimeout   time.Duration `yaml:"idleTimeout"`
+	RatePerMinute int           `yaml:"ratePerMinute"`
+}
+
+type conn struct {
+	client   *smtp.Client
+	sent     int
+	lastUsed time.Time
+}
+
+type pool struct {
+	dial        func() (*smtp.Client, error)
+	name        string
+	auth        sasl.Client
+	maxMessages int
+	idleTimeout time.Duration
+	limiter     *rate.Limiter
+	slots       chan struct{}
+	idle        []*conn
+	mutex       sync.Mutex
+}
+
+func newPool(cfg *PoolConfig, name string, auth sasl.Client, dial func() (*smtp.Client, error)) *pool {
+	limit := rate.Inf
+	if cfg.RatePerMinute > 0 {
+		limit = rate.Limit(float64(cfg.RatePerMinute) / 60)
+	}
+	return &pool{
+		dial:        dial,
+		name:        name,
+		auth:        auth,
+		maxMessages: cfg.MaxMessages,
+		idleTimeout: cfg.IdleTimeout,
+		limiter:     rate.NewLimiter(limit, 1),
+		slots:       make(chan struct{}, max(cfg.Size, 1)),
+	}
+}
+
+func (p *pool) open() (*conn, error) {
+	c, err := p.dial()
+	if err != nil {
+		return nil, err
+	}
+	if err = c.Hello(p.name); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err = c.Auth(p.auth); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return &conn{client: c}, nil
+}
+
+func (p *pool) get(ctx context.Context) (*conn, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	for {
+		p.mutex.Lock()
+		if len(p.idle) == 0 {
+			p.mutex.Unlock()
+			break
+		}
+		c := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		p.mutex.Unlock()
+		if p.idleTimeout > 0 && time.Since(c.lastUsed) > p.idleTimeout {
+			c.client.Close()
+			continue
+		}
+		if err := c.client.Reset(); err != nil {
+			c.client.Close()
+			continue
+		}
+		return c, nil
+	}
+	c, err := p.open()
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return c, nil
+}
+
+func (p *pool) put(c *conn, err error) {
+	defer func() { <-p.slots }()
+	if err != nil || (p.maxMessages > 0 && c.sent >= p.maxMessages) {
+		if err == nil {
+			c.client.Quit()
+		}
+		c.client.Close()
+		return
+	}
+	c.lastUsed = time.Now()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.idle = append(p.idle, c)
+}
+
+func (p *pool) send(ctx context.Context, from, to string, message []byte) (err error) {
+	if err = p.limiter.Wait(ctx); err != nil {
+		return
+	}
+	c, err := p.get(ctx)
+	if err != nil {
+		return
+	}
+	defer func() { p.put(c, err) }()
+	if err = c.client.Mail(from, nil); err != nil {
+		return
+	}
+	if err = c.client.Rcpt(to, nil); err != nil {
+		return
+	}
+	wc, err := c.client.Data()
+	if err != nil {
+		return
+	}
+	if _, err = wc.Write(message); err != nil {
+		return
+	}
+	if err = wc.Close(); err != nil {
+		return
+	}
+	c.sent++
+	return
+}
+
+func (p *pool) close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, c := range p.idle {
+		c.client.Quit()
+		c.client.Close()
+	}
+	p.idle = nil
+}
@@ -0,0 +1,135 @@
+package smtp
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-sasl"
+	"github.com/emersion/go-smtp"
+)
+
+type poolBackend struct {
+	conns    atomic.Int32
+	active   atomic.Int32
+	peak     atomic.Int32
+	messages atomic.Int32
+}
+
+type poolSession struct {
+	backend *poolBackend
+}
+
+func (s *poolSession) AuthMechanisms() []string {
+	return []string{sasl.Plain}
+}
+
+func (s *poolSession) Auth(mech string) (sasl.Server, error) {
+	return sasl.NewPlainServer(func(identity, username, password string) error { return nil }), nil
+}
+
+func (s *poolSession) Mail(from string, opts *smtp.MailOptions) error {
+	return nil
+}
+
+func (s *poolSession) Rcpt(to string, opts *smtp.RcptOptions) error {
+	return nil
+}
+
+func (s *poolSession) Data(r io.Reader) error {
+	active := s.backend.active.Add(1)
+	defer s.backend.active.Add(-1)
+	for {
+		peak := s.backend.peak.Load()
+		if active <= peak || s.backend.peak.CompareAndSwap(peak, active) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	s.backend.messages.Add(1)
+	_, err := io.Copy(io.Discard, r)
+	return err
+}
+
+func (s *poolSession) Reset() {}
+
+func (s *poolSession) Logout() error {
+	return nil
+}
+
+func startPoolServer(t *testing.T) (*poolBackend, string) {
+	backend := &poolBackend{}
+	server := smtp.NewServer(smtp.BackendFunc(func(c *smtp.Conn) (smtp.Session, error) {
+		backend.conns.Add(1)
+		return &poolSession{backend}, nil
+	}))
+	server.AllowInsecureAuth = true
+	server.Domain = "localhost"
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(l)
+	t.Cleanup(func() { server.Close() })
+	return backend, l.Addr().String()
+}
+
+func TestPool(t *testing.T) {
+	backend, addr := startPoolServer(t)
+	p := newPool(
+		&PoolConfig{Size: 2, MaxMessages: 5},
+		"localhost",
+		sasl.NewPlainClient("", "golang", "secret"),
+		func() (*smtp.Client, error) { return smtp.Dial(addr) },
+	)
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- p.send(context.Background(), from.Address, to.Address, []byte("Subject: Hello\r\n\r\nHello\r\n"))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.close()
+	if got := backend.messages.Load(); got != 20 {
+		t.Fatalf("expected messages: %d, got: %d", 20, got)
+	}
+	if got := backend.peak.Load(); got > 2 {
+		t.Fatalf("expected at most %d concurrent deliveries, got: %d", 2, got)
+	}
+	if got := backend.conns.Load(); got < 4 || got > 6 {
+		t.Fatalf("expected between %d and %d connections, got: %d", 4, 6, got)
+	}
+}
+
+func TestPoolRateLimit(t *testing.T) {
+	_, addr := startPoolServer(t)
+	p := newPool(
+		&PoolConfig{Size: 1, RatePerMinute: 60},
+		"localhost",
+		sasl.NewPlainClient("", "golang", "secret"),
+		func() (*smtp.Client, error) { return smtp.Dial(addr) },
+	)
+	defer p.close()
+	message := []byte("Subject: Hello\r\n\r\nHello\r\n")
+	if err := p.send(context.Background(), from.Address, to.Address, message); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := p.send(ctx, from.Address, to.Address, message); err == nil {
+		t.Fatal("expected rate limit to block the second message")
+	}
+}
@@ -0,0 +1,34 @@
+package smtp
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	MailStatusPending = "pending"
+	MailStatusSent    = "sent"
+	MailStatusDead    = "dead"
+)
+
+type QueuedMail struct {
+	Id        int64
+	Sender    string
+	Recipient string
+	Message   []byte
+	Attempts  int
+}
+
+type EnqueueMailOpts struct {
+	Sender    string
+	Recipient string
+	Message   []byte
+}
+
+type Queue interface {
+	Enqueue(context.Context, EnqueueMailOpts) (int64, error)
+	Claim(context.Context, int, time.Duration) ([]QueuedMail, error)
+	MarkSent(context.Context, int64) error
+	MarkFailed(context.Context, int64, string, time.Duration) error
+	MarkDead(context.Context, int64, string) error
+}
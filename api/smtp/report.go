@@ -0,0 +1,152 @@
+package smtp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+const (
+	SuppressionBounce    = "bounce"
+	SuppressionComplaint = "complaint"
+)
+
+var (
+	ErrNotReport     = errors.New("smtp: message is not a delivery status or feedback report")
+	ErrNotSuppressed = errors.New("smtp: address is not suppressed")
+)
+
+type Report struct {
+	Recipient string
+	Kind      string
+	Reason    string
+}
+
+type SuppressOpts struct {
+	Email  string
+	Kind   string
+	Reason string
+}
+
+type SuppressionList interface {
+	Suppress(context.Context, SuppressOpts) error
+	IsSuppressed(context.Context, string) (bool, error)
+	Remove(context.Context, string) error
+}
+
+func ParseReport(r io.Reader) ([]Report, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" {
+		return nil, ErrNotReport
+	}
+	reportType := strings.ToLower(params["report-type"])
+	if reportType != "delivery-status" && reportType != "feedback-report" {
+		return nil, ErrNotReport
+	}
+	var reports []Report
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return reports, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		mediaType, _, err := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "message/delivery-status", "message/global-delivery-status":
+			bounces, err := parseDeliveryStatus(p)
+			if err != nil {
+				return nil, err
+			}
+			reports = append(reports, bounces...)
+		case "message/feedback-report":
+			complaints, err := parseFeedbackReport(p)
+			if err != nil {
+				return nil, err
+			}
+			reports = append(reports, complaints...)
+		}
+	}
+}
+
+func parseTypedField(value string) string {
+	if _, address, ok := strings.Cut(value, ";"); ok {
+		value = address
+	}
+	return strings.Trim(strings.TrimSpace(value), "<>")
+}
+
+func readFieldGroups(r io.Reader) ([]textproto.MIMEHeader, error) {
+	tp := textproto.NewReader(bufio.NewReader(r))
+	var groups []textproto.MIMEHeader
+	for {
+		h, err := tp.ReadMIMEHeader()
+		if len(h) > 0 {
+			groups = append(groups, h)
+		}
+		if err == io.EOF {
+			return groups, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func parseDeliveryStatus(r io.Reader) ([]Report, error) {
+	groups, err := readFieldGroups(r)
+	if err != nil {
+		return nil, err
+	}
+	var reports []Report
+	for _, h := range groups {
+		recipient := parseTypedField(h.Get("Final-Recipient"))
+		if recipient == "" {
+			continue
+		}
+		if !strings.EqualFold(h.Get("Action"), "failed") || !strings.HasPrefix(strings.TrimSpace(h.Get("Status")), "5") {
+			continue
+		}
+		reason := strings.TrimSpace(h.Get("Status"))
+		if diagnostic := parseTypedField(h.Get("Diagnostic-Code")); diagnostic != "" {
+			reason += " " + diagnostic
+		}
+		reports = append(reports, Report{recipient, SuppressionBounce, reason})
+	}
+	return reports, nil
+}
+
+func parseFeedbackReport(r io.Reader) ([]Report, error) {
+	groups, err := readFieldGroups(r)
+	if err != nil {
+		return nil, err
+	}
+	var reports []Report
+	for _, h := range groups {
+		feedbackType := strings.ToLower(strings.TrimSpace(h.Get("Feedback-Type")))
+		if feedbackType == "" {
+			continue
+		}
+		for _, recipient := range h.Values("Original-Rcpt-To") {
+			if recipient = parseTypedField(recipient); recipient != "" {
+				reports = append(reports, Report{recipient, SuppressionComplaint, feedbackType})
+			}
+		}
+	}
+	return reports, nil
+}
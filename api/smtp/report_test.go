@@ -0,0 +1,123 @@
+package smtp
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const dsn = "From: Mail Delivery System <MAILER-DAEMON@example.com>\r\n" +
+	"To: john@example.com\r\n" +
+	"Subject: Undelivered Mail Returned to Sender\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/report; report-type=delivery-status; boundary=\"b1\"\r\n" +
+	"\r\n" +
+	"--b1\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"Your message could not be delivered.\r\n" +
+	"--b1\r\n" +
+	"Content-Type: message/delivery-status\r\n" +
+	"\r\n" +
+	"Reporting-MTA: dns; mx.example.com\r\n" +
+	"Arrival-Date: Thu, 02 Jan 2025 03:04:05 +0000\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; bob@example.com\r\n" +
+	"Action: failed\r\n" +
+	"Status: 5.1.1\r\n" +
+	"Diagnostic-Code: smtp; 550 5.1.1 user unknown\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; <alice@example.com>\r\n" +
+	"Action: delayed\r\n" +
+	"Status: 4.4.1\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; eve@example.com\r\n" +
+	"Action: failed\r\n" +
+	"Status: 5.2.2\r\n" +
+	"--b1--\r\n"
+
+const arf = "From: abuse@example.net\r\n" +
+	"To: john@example.com\r\n" +
+	"Subject: Abuse report\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/report; report-type=feedback-report; boundary=\"b2\"\r\n" +
+	"\r\n" +
+	"--b2\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"This is an email abuse report.\r\n" +
+	"--b2\r\n" +
+	"Content-Type: message/feedback-report\r\n" +
+	"\r\n" +
+	"Feedback-Type: abuse\r\n" +
+	"User-Agent: SomeGenerator/1.0\r\n" +
+	"Version: 1\r\n" +
+	"Original-Rcpt-To: <bob@example.com>\r\n" +
+	"--b2--\r\n"
+
+func TestParseReport(t *testing.T) {
+	tests := []struct {
+		raw     string
+		reports []Report
+	}{
+		{dsn, []Report{
+			{"bob@example.com", SuppressionBounce, "5.1.1 550 5.1.1 user unknown"},
+			{"eve@example.com", SuppressionBounce, "5.2.2"},
+		}},
+		{arf, []Report{{"bob@example.com", SuppressionComplaint, "abuse"}}},
+	}
+	for _, test := range tests {
+		reports, err := ParseReport(strings.NewReader(test.raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(reports, test.reports) {
+			t.Fatalf("expected reports: %v, got: %v", test.reports, reports)
+		}
+	}
+	if _, err := ParseReport(strings.NewReader("Subject: Hello\r\nContent-Type: text/plain\r\n\r\nHello\r\n")); err != ErrNotReport {
+		t.Fatalf("expected error: %v, got: %v", ErrNotReport, err)
+	}
+}
+
+type suppressionDB map[string]bool
+
+func (db suppressionDB) Suppress(ctx context.Context, opts SuppressOpts) error {
+	db[opts.Email] = true
+	return nil
+}
+
+func (db suppressionDB) IsSuppressed(ctx context.Context, email string) (bool, error) {
+	return db[email], nil
+}
+
+func (db suppressionDB) Remove(ctx context.Context, email string) error {
+	delete(db, email)
+	return nil
+}
+
+func TestSendSuppressed(t *testing.T) {
+	suppressedDB := &mailDB{status: make(map[int64]string)}
+	suppressedQueue := queue
+	suppressedQueue.PollInterval = time.Hour
+	suppressedSvc, err := NewService(&Config{
+		Host:        "localhost",
+		Port:        "25",
+		From:        from.Address,
+		Queue:       suppressedQueue,
+		DB:          suppressedDB,
+		Suppression: suppressionDB{to.Address: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer suppressedSvc.Close()
+	if err = suppressedSvc.Send(context.Background(), to, tmpl, data); err != nil {
+		t.Fatal(err)
+	}
+	if len(suppressedDB.status) != 0 {
+		t.Fatalf("expected no enqueued mails, got: %d", len(suppressedDB.status))
+	}
+}
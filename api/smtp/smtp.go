@@ -1,164 +1,257 @@
 package smtp
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
-	"html/template"
-	"io"
 	"log"
-	"net"
+	"math/rand/v2"
+	"net/mail"
 	"sync"
 	"time"
+)
+
+const (
+	BackendSMTP   = "smtp"
+	BackendFile   = "file"
+	BackendMemory = "memory"
+)
 
-	"github.com/emersion/go-sasl"
-	"github.com/emersion/go-smtp"
+var (
+	ErrUnknownBackend   = errors.New("smtp: unknown backend")
+	ErrUnknownTransport = errors.New("smtp: unknown transport")
+	ErrMissingAddress   = errors.New("smtp: host and port are required")
+	ErrMissingDir       = errors.New("smtp: directory is required")
+	ErrSuppressed       = errors.New("smtp: recipient is suppressed")
+	ErrInvalidQueue     = errors.New("smtp: invalid queue config")
 )
 
-type Headers struct {
-	From string
-	To   string
-	Date time.Time
+type Config struct {
+	Backend     string      `yaml:"backend"`
+	Transport   string      `yaml:"transport"`
+	Dir         string      `yaml:"dir"`
+	Host        string      `env:"HOST" envDefault:""`
+	Port        string      `env:"PORT" envDefault:""`
+	Username    string      `env:"USERNAME" envDefault:""`
+	Password    string      `env:"PASSWORD" envDefault:""`
+	Name        string      `yaml:"name"`
+	From        string      `yaml:"from"`
+	FromName    string      `yaml:"fromName"`
+	Queue       QueueConfig `yaml:"queue"`
+	Pool        PoolConfig  `yaml:"pool"`
+	HTTP        HTTPConfig  `yaml:"http" envPrefix:"HTTP_"`
+	DKIM        DKIMConfig  `yaml:"dkim" envPrefix:"DKIM_"`
+	DB          Queue
+	Suppression SuppressionList
+	ErrorLog    *log.Logger
+	TLSConfig   *tls.Config
 }
 
-func Write(w io.Writer, headers Headers, tmpl *template.Template, data any) error {
-	var err error
-	for _, header := range []struct {
-		key   string
-		value string
-	}{
-		{"From", fmt.Sprintf("<%s>", headers.From)},
-		{"To", fmt.Sprintf("<%s>", headers.To)},
-		{"Subject", tmpl.Name()},
-		{"Date", headers.Date.Format(time.RFC1123)},
-		{"Mime-Version", "1.0"},
-		{"Content-Type", `text/html; charset="utf-8"`},
-	} {
-		if _, err = fmt.Fprintf(w, "%s: %s\r\n", header.key, header.value); err != nil {
-			return err
-		}
-	}
-	if _, err := fmt.Fprint(w, "\r\n"); err != nil {
-		return err
-	}
-	if err = tmpl.Execute(w, data); err != nil {
-		return err
-	}
-	if _, err = fmt.Fprint(w, "\r\n"); err != nil {
-		return err
+type QueueConfig struct {
+	Workers      int           `yaml:"workers"`
+	BatchSize    int           `yaml:"batchSize"`
+	PollInterval time.Duration `yaml:"pollInterval"`
+	Lease        time.Duration `yaml:"lease"`
+	MaxAttempts  int           `yaml:"maxAttempts"`
+	MinBackoff   time.Duration `yaml:"minBackoff"`
+	MaxBackoff   time.Duration `yaml:"maxBackoff"`
+}
+
+func (cfg *QueueConfig) Validate() error {
+	switch {
+	case cfg.Workers < 1:
+		return fmt.Errorf("%w: workers must be positive", ErrInvalidQueue)
+	case cfg.BatchSize < 1:
+		return fmt.Errorf("%w: batch size must be positive", ErrInvalidQueue)
+	case cfg.Lease <= 0:
+		return fmt.Errorf("%w: lease must be positive", ErrInvalidQueue)
+	case cfg.MaxAttempts < 1:
+		return fmt.Errorf("%w: max attempts must be positive", ErrInvalidQueue)
+	case cfg.MinBackoff <= 0:
+		return fmt.Errorf("%w: min backoff must be positive", ErrInvalidQueue)
+	case cfg.MaxBackoff < cfg.MinBackoff:
+		return fmt.Errorf("%w: max backoff must not be lower than min backoff", ErrInvalidQueue)
 	}
 	return nil
 }
 
-type Config struct {
-	Host      string `env:"HOST"`
-	Port      string `env:"PORT"`
-	Username  string `env:"USERNAME"`
-	Password  string `env:"PASSWORD"`
-	Name      string `yaml:"name"`
-	From      string `yaml:"from"`
-	ErrorLog  *log.Logger
-	TLSConfig *tls.Config
+func (cfg *QueueConfig) Backoff(attempts int) time.Duration {
+	delay := cfg.MaxBackoff
+	if attempts < 1 {
+		attempts = 1
+	}
+	if shift := attempts - 1; shift < 63 && cfg.MinBackoff <= cfg.MaxBackoff>>shift {
+		delay = cfg.MinBackoff << shift
+	}
+	return delay/2 + rand.N(delay/2+1)
 }
 
 type Service interface {
 	Ping() error
-	Send(to string, tmpl *template.Template, data any)
+	Send(ctx context.Context, to mail.Address, tmpl *Template, data any) error
 	Close()
 }
 
-func NewService(cfg *Config) Service {
+func NewBackend(cfg *Config) (Service, error) {
+	switch cfg.Backend {
+	case BackendSMTP, "":
+		return NewService(cfg)
+	case BackendFile:
+		return NewFileService(cfg)
+	case BackendMemory:
+		return NewMemoryService(cfg)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownBackend, cfg.Backend)
+	}
+}
+
+func NewService(cfg *Config) (Service, error) {
+	renderer, err := newRenderer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := NewTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err = cfg.Queue.Validate(); err != nil {
+		return nil, err
+	}
 	if cfg.ErrorLog == nil {
 		cfg.ErrorLog = log.Default()
 	}
-	return &service{
-		addr:      net.JoinHostPort(cfg.Host, cfg.Port),
-		auth:      sasl.NewPlainClient("", cfg.Username, cfg.Password),
-		name:      cfg.Name,
-		from:      cfg.From,
-		errorLog:  cfg.ErrorLog,
-		tlsConfig: cfg.TLSConfig,
+	if cfg.Queue.PollInterval <= 0 {
+		cfg.Queue.PollInterval = time.Second
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &service{
+		renderer:    renderer,
+		transport:   transport,
+		queue:       cfg.Queue,
+		db:          cfg.DB,
+		suppression: cfg.Suppression,
+		errorLog:    cfg.ErrorLog,
+		notify:      make(chan struct{}, 1),
+		ctx:         ctx,
+		cancel:      cancel,
 	}
+	for range cfg.Queue.Workers {
+		s.wg.Add(1)
+		go s.work()
+	}
+	return s, nil
 }
 
 type service struct {
-	addr      string
-	auth      sasl.Client
-	name      string
-	from      string
-	tlsConfig *tls.Config
-	errorLog  *log.Logger
-	wg        sync.WaitGroup
+	*renderer
+	transport   Transport
+	queue       QueueConfig
+	db          Queue
+	suppression SuppressionList
+	errorLog    *log.Logger
+	notify      chan struct{}
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+func (s *service) Ping() error {
+	return s.transport.Ping()
 }
 
-func (s *service) connect() (*smtp.Client, error) {
-	if s.tlsConfig != nil {
-		return smtp.DialStartTLS(s.addr, s.tlsConfig)
-	} else {
-		return smtp.Dial(s.addr)
+func (s *service) isSuppressed(ctx context.Context, address string) (bool, error) {
+	if s.suppression == nil {
+		return false, nil
 	}
+	return s.suppression.IsSuppressed(ctx, address)
 }
 
-func (s *service) Ping() error {
-	c, err := s.connect()
-	if err != nil {
+func (s *service) Send(ctx context.Context, to mail.Address, tmpl *Template, data any) error {
+	suppressed, err := s.isSuppressed(ctx, to.Address)
+	if err != nil || suppressed {
 		return err
 	}
-	defer c.Close()
-	if err = c.Hello(s.name); err != nil {
+	m, raw, err := s.render(to, tmpl, data)
+	if err != nil {
 		return err
 	}
-	if err = c.Noop(); err != nil {
+	if _, err = s.db.Enqueue(ctx, EnqueueMailOpts{
+		Sender:    m.From.Address,
+		Recipient: m.To.Address,
+		Message:   raw,
+	}); err != nil {
 		return err
 	}
-	return c.Quit()
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
 }
 
-func (s *service) send(to string, tmpl *template.Template, data any) error {
-	c, err := s.connect()
-	if err != nil {
-		return err
+func (s *service) work() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.queue.PollInterval)
+	defer ticker.Stop()
+	for {
+		s.process()
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.notify:
+		}
 	}
-	defer c.Close()
-	if err = c.Hello(s.name); err != nil {
-		return err
+}
+
+func (s *service) process() {
+	for {
+		mails, err := s.db.Claim(s.ctx, s.queue.BatchSize, s.queue.Lease)
+		if err != nil {
+			if s.ctx.Err() == nil {
+				s.errorLog.Print(err)
+			}
+			return
+		}
+		if len(mails) == 0 {
+			return
+		}
+		for _, mail := range mails {
+			s.handle(mail)
+		}
 	}
-	if err = c.Auth(s.auth); err != nil {
-		return err
+}
+
+func (s *service) handle(mail QueuedMail) {
+	suppressed, err := s.isSuppressed(s.ctx, mail.Recipient)
+	if err == nil && !suppressed {
+		err = s.transport.Send(s.ctx, mail.Sender, mail.Recipient, mail.Message)
 	}
-	if err = c.Mail(s.from, nil); err != nil {
-		return err
+	if err != nil && s.ctx.Err() != nil {
+		return
 	}
-	if err = c.Rcpt(to, nil); err != nil {
-		return err
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	switch {
+	case suppressed:
+		err = s.db.MarkDead(ctx, mail.Id, ErrSuppressed.Error())
+	case err == nil:
+		err = s.db.MarkSent(ctx, mail.Id)
+	case mail.Attempts >= s.queue.MaxAttempts:
+		s.errorLog.Printf("mail %d is dead after %d attempts: %v", mail.Id, mail.Attempts, err)
+		err = s.db.MarkDead(ctx, mail.Id, err.Error())
+	default:
+		s.errorLog.Printf("mail %d failed on attempt %d: %v", mail.Id, mail.Attempts, err)
+		err = s.db.MarkFailed(ctx, mail.Id, err.Error(), s.queue.Backoff(mail.Attempts))
 	}
-	wc, err := c.Data()
 	if err != nil {
-		return err
+		s.errorLog.Print(err)
 	}
-	if err = Write(
-		wc,
-		Headers{s.from, to, time.Now()},
-		tmpl,
-		data,
-	); err != nil {
-		return err
-	}
-	if err = wc.Close(); err != nil {
-		return err
-	}
-	return c.Quit()
-}
-
-func (s *service) Send(to string, tmpl *template.Template, data any) {
-	s.wg.Add(1)
-	go func() {
-		defer s.wg.Done()
-		if err := s.send(to, tmpl, data); err != nil {
-			s.errorLog.Print(err)
-		}
-	}()
 }
 
 func (s *service) Close() {
+	s.cancel()
 	s.wg.Wait()
+	s.transport.Close()
 }
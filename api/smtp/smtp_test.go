@@ -2,12 +2,20 @@ package smtp
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"html/template"
+	"io"
 	"log"
+	"mime"
+	"mime/multipart"
 	"net"
+	"net/mail"
 	"os"
 	"strings"
+	"sync"
 	"testing"
+	texttemplate "text/template"
 	"time"
 
 	"github.com/ory/dockertest/v3"
@@ -16,23 +24,91 @@ import (
 
 var (
 	errorLog bytes.Buffer
+	db       = &mailDB{status: make(map[int64]string)}
 	svc      Service
-	headers  = Headers{
-		From: "john@example.com",
-		To:   "bob@example.com",
-		Date: time.Time{},
+	from     = mail.Address{Name: "John", Address: "john@example.com"}
+	to       = mail.Address{Name: "Bob", Address: "bob@example.com"}
+	tmpl     = &Template{
+		Subject: "Greeting",
+		Text:    texttemplate.Must(texttemplate.New("Greeting").Parse("Hello, {{.Name}}!\nThis is a sample text email content.")),
+		HTML:    template.Must(template.New("Greeting").Parse(`<h1>Hello, {{.Name}}!</h1><p>This is a sample HTML email content.</p>`)),
+	}
+	data  = struct{ Name string }{Name: "Bob"}
+	queue = QueueConfig{
+		Workers:      1,
+		BatchSize:    1,
+		PollInterval: 100 * time.Millisecond,
+		Lease:        time.Minute,
+		MaxAttempts:  1,
+		MinBackoff:   time.Second,
+		MaxBackoff:   time.Minute,
 	}
-	tmpl = template.Must(template.New("Greeting").Parse(`<h1>Hello, {{.Name}}!</h1><p>This is a sample HTML email content.</p>`))
-	data = struct{ Name string }{Name: "Bob"}
 )
 
+type mailDB struct {
+	mails  []QueuedMail
+	status map[int64]string
+	mutex  sync.Mutex
+}
+
+func (db *mailDB) Enqueue(ctx context.Context, opts EnqueueMailOpts) (int64, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	id := int64(len(db.status) + 1)
+	db.mails = append(db.mails, QueuedMail{Id: id, Sender: opts.Sender, Recipient: opts.Recipient, Message: opts.Message})
+	db.status[id] = MailStatusPending
+	return id, nil
+}
+
+func (db *mailDB) Claim(ctx context.Context, limit int, lease time.Duration) ([]QueuedMail, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	n := min(limit, len(db.mails))
+	mails := db.mails[:n]
+	db.mails = db.mails[n:]
+	for i := range mails {
+		mails[i].Attempts++
+	}
+	return mails, nil
+}
+
+func (db *mailDB) MarkSent(ctx context.Context, id int64) error {
+	return db.mark(id, MailStatusSent)
+}
+
+func (db *mailDB) MarkFailed(ctx context.Context, id int64, reason string, delay time.Duration) error {
+	return db.mark(id, MailStatusPending)
+}
+
+func (db *mailDB) MarkDead(ctx context.Context, id int64, reason string) error {
+	return db.mark(id, MailStatusDead)
+}
+
+func (db *mailDB) mark(id int64, status string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.status[id] = status
+	return nil
+}
+
+func (db *mailDB) GetStatus(ctx context.Context, id int64) (string, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	status, ok := db.status[id]
+	if !ok {
+		return "", errors.New("mail not found")
+	}
+	return status, nil
+}
+
 func TestMain(m *testing.M) {
 	pool, err := dockertest.NewPool("")
-	if err != nil {
-		log.Fatal(err)
+	if err == nil {
+		err = pool.Client.Ping()
 	}
-	if err = pool.Client.Ping(); err != nil {
-		log.Fatal(err)
+	if err != nil {
+		log.Printf("skipping maildev tests: %v", err)
+		os.Exit(m.Run())
 	}
 	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
 		Repository: "maildev/maildev",
@@ -51,15 +127,21 @@ func TestMain(m *testing.M) {
 	}
 	resource.Expire(20)
 	host, port, _ := net.SplitHostPort(resource.GetHostPort("1025/tcp"))
-	svc = NewService(&Config{
+	svc, err = NewService(&Config{
 		Host:     host,
 		Port:     port,
 		Name:     "localhost",
 		Username: "golang",
 		Password: "secret",
-		From:     headers.From,
+		From:     from.Address,
+		FromName: from.Name,
+		Queue:    queue,
+		DB:       db,
 		ErrorLog: log.New(&errorLog, "", 0),
 	})
+	if err != nil {
+		log.Fatal(err)
+	}
 	pool.MaxWait = 20 * time.Second
 	if err = pool.Retry(svc.Ping); err != nil {
 		log.Fatal(err)
@@ -72,19 +154,165 @@ func TestMain(m *testing.M) {
 }
 
 func TestWrite(t *testing.T) {
+	text, html, err := tmpl.Render(data)
+	if err != nil {
+		t.Fatal(err)
+	}
 	var sb strings.Builder
-	if err := Write(&sb, headers, tmpl, data); err != nil {
+	if err = Write(&sb, &Message{
+		From:      mail.Address{Name: "Łukasz Żółw", Address: from.Address},
+		To:        to,
+		Subject:   "Zażółć gęślą jaźń",
+		Date:      time.Date(2025, time.January, 2, 3, 4, 5, 0, time.UTC),
+		MessageID: "<1@example.com>",
+		Text:      text,
+		HTML:      html,
+	}); err != nil {
 		t.Fatal(err)
 	}
-	expected := "From: <john@example.com>\r\nTo: <bob@example.com>\r\nSubject: Greeting\r\nDate: Mon, 01 Jan 0001 00:00:00 UTC\r\nMime-Version: 1.0\r\nContent-Type: text/html; charset=\"utf-8\"\r\n\r\n<h1>Hello, Bob!</h1><p>This is a sample HTML email content.</p>\r\n"
-	got := sb.String()
-	if got != expected {
-		t.Fatalf("expected: %s, got: %s", expected, got)
+	raw := sb.String()
+	if !strings.Contains(raw, "Subject: =?utf-8?q?Za=C5=BC=C3=B3=C5=82=C4=87_g=C4=99=C5=9Bl=C4=85_ja=C5=BA=C5=84?=\r\n") {
+		t.Fatalf("expected encoded subject, got: %s", raw)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec mime.WordDecoder
+	for key, expected := range map[string]string{
+		"Subject":      "Zażółć gęślą jaźń",
+		"Date":         "Thu, 02 Jan 2025 03:04:05 +0000",
+		"Message-Id":   "<1@example.com>",
+		"Mime-Version": "1.0",
+	} {
+		got, err := dec.DecodeHeader(msg.Header.Get(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != expected {
+			t.Fatalf("expected %s header: %q, got: %q", key, expected, got)
+		}
+	}
+	for key, expected := range map[string]mail.Address{
+		"From": {Name: "Łukasz Żółw", Address: from.Address},
+		"To":   to,
+	} {
+		got, err := msg.Header.AddressList(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || *got[0] != expected {
+			t.Fatalf("expected %s header: %v, got: %v", key, expected, got)
+		}
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("expected media type: multipart/alternative, got: %s", mediaType)
+	}
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for _, expected := range []struct {
+		contentType string
+		body        string
+	}{
+		{`text/plain; charset="utf-8"`, "Hello, Bob!\r\nThis is a sample text email content."},
+		{`text/html; charset="utf-8"`, "<h1>Hello, Bob!</h1><p>This is a sample HTML email content.</p>"},
+	} {
+		part, err := r.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := part.Header.Get("Content-Type"); got != expected.contentType {
+			t.Fatalf("expected content type: %s, got: %s", expected.contentType, got)
+		}
+		body, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != expected.body {
+			t.Fatalf("expected body: %q, got: %q", expected.body, body)
+		}
+	}
+	if _, err = r.NextPart(); err != io.EOF {
+		t.Fatalf("expected error: %v, got: %v", io.EOF, err)
+	}
+}
+
+func TestQueueConfigValidate(t *testing.T) {
+	tests := []struct {
+		modify func(*QueueConfig)
+		err    error
+	}{
+		{func(cfg *QueueConfig) {}, nil},
+		{func(cfg *QueueConfig) { cfg.MaxBackoff = cfg.MinBackoff }, nil},
+		{func(cfg *QueueConfig) { cfg.Workers = 0 }, ErrInvalidQueue},
+		{func(cfg *QueueConfig) { cfg.BatchSize = -1 }, ErrInvalidQueue},
+		{func(cfg *QueueConfig) { cfg.Lease = 0 }, ErrInvalidQueue},
+		{func(cfg *QueueConfig) { cfg.MaxAttempts = 0 }, ErrInvalidQueue},
+		{func(cfg *QueueConfig) { cfg.MinBackoff = 0 }, ErrInvalidQueue},
+		{func(cfg *QueueConfig) { cfg.MinBackoff, cfg.MaxBackoff = -time.Second, -time.Second }, ErrInvalidQueue},
+		{func(cfg *QueueConfig) { cfg.MaxBackoff = time.Millisecond }, ErrInvalidQueue},
+	}
+	for i, test := range tests {
+		cfg := queue
+		test.modify(&cfg)
+		if err := cfg.Validate(); !errors.Is(err, test.err) {
+			t.Fatalf("expected error: %v for case %d, got: %v", test.err, i+1, err)
+		}
+	}
+	if _, err := NewService(&Config{Host: "localhost", Port: "25", From: from.Address, DB: db}); !errors.Is(err, ErrInvalidQueue) {
+		t.Fatalf("expected error: %v, got: %v", ErrInvalidQueue, err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		min      time.Duration
+		attempts int
+		max      time.Duration
+	}{
+		{time.Second, 1, time.Second},
+		{time.Second, 2, 2 * time.Second},
+		{time.Second, 4, 8 * time.Second},
+		{time.Second, 7, time.Minute},
+		{time.Second, 100, time.Minute},
+		{30 * time.Second, 30, time.Minute},
+		{30 * time.Second, 31, time.Minute},
+		{30 * time.Second, 64, time.Minute},
+		{time.Nanosecond, 63, time.Minute},
+	}
+	for _, test := range tests {
+		cfg := &QueueConfig{MinBackoff: test.min, MaxBackoff: time.Minute}
+		delay := cfg.Backoff(test.attempts)
+		if delay < test.max/2 || delay > test.max {
+			t.Fatalf("expected delay between %v and %v for %d attempts, got: %v", test.max/2, test.max, test.attempts, delay)
+		}
 	}
 }
 
 func TestSend(t *testing.T) {
-	svc.Send(headers.To, tmpl, data)
+	if svc == nil {
+		t.Skip("docker is not available")
+	}
+	if err := svc.Send(context.Background(), to, tmpl, data); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		status, err := db.GetStatus(context.Background(), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status == MailStatusSent {
+			break
+		}
+		if status == MailStatusDead || time.Now().After(deadline) {
+			t.Fatalf("expected status: %s, got: %s", MailStatusSent, status)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
 	svc.Close()
 	if errorLog.Len() != 0 {
 		t.Fatal(errorLog.String())
@@ -0,0 +1,231 @@
+package smtp
+
+import (
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"maps"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+const (
+	LayoutName  = "layout"
+	SubjectName = "subject"
+)
+
+var (
+	ErrTemplateNotFound   = errors.New("smtp: template not found")
+	ErrMissingSubject     = errors.New("smtp: template does not define a subject")
+	ErrMissingLocale      = errors.New("smtp: default locale has no templates")
+	ErrIncompleteLocale   = errors.New("smtp: template is missing in default locale")
+	ErrMissingTemplateDir = errors.New("smtp: template directory is required")
+)
+
+var funcs = map[string]any{
+	"minutes": func(d time.Duration) int { return int(d.Minutes()) },
+	"hours":   func(d time.Duration) int { return int(d.Hours()) },
+}
+
+type TemplatesConfig struct {
+	Dir           string `yaml:"dir"`
+	DefaultLocale string `yaml:"defaultLocale"`
+}
+
+type Templates struct {
+	locales   []string
+	matcher   language.Matcher
+	templates map[string]map[string]*Template
+}
+
+func LoadTemplates(cfg *TemplatesConfig) (*Templates, error) {
+	if cfg.Dir == "" {
+		return nil, ErrMissingTemplateDir
+	}
+	layout, err := os.ReadFile(filepath.Join(cfg.Dir, LayoutName+".txt"))
+	if err != nil {
+		return nil, err
+	}
+	text, err := texttemplate.New(LayoutName).Funcs(funcs).Parse(string(layout))
+	if err != nil {
+		return nil, err
+	}
+	if layout, err = os.ReadFile(filepath.Join(cfg.Dir, LayoutName+".html")); err != nil {
+		return nil, err
+	}
+	html, err := htmltemplate.New(LayoutName).Funcs(funcs).Parse(string(layout))
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	t := &Templates{templates: make(map[string]map[string]*Template)}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		tag, err := language.Parse(entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		templates, err := loadLocale(filepath.Join(cfg.Dir, entry.Name()), text, html)
+		if err != nil {
+			return nil, err
+		}
+		t.templates[tag.String()] = templates
+	}
+	defaultTag, err := language.Parse(cfg.DefaultLocale)
+	if err != nil {
+		return nil, err
+	}
+	defaultTemplates, ok := t.templates[defaultTag.String()]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrMissingLocale, cfg.DefaultLocale)
+	}
+	tags := []language.Tag{defaultTag}
+	t.locales = []string{defaultTag.String()}
+	for _, locale := range slices.Sorted(maps.Keys(t.templates)) {
+		for name := range t.templates[locale] {
+			if _, ok = defaultTemplates[name]; !ok {
+				return nil, fmt.Errorf("%w: %s/%s", ErrIncompleteLocale, locale, name)
+			}
+		}
+		if locale != t.locales[0] {
+			tags = append(tags, language.Make(locale))
+			t.locales = append(t.locales, locale)
+		}
+	}
+	t.matcher = language.NewMatcher(tags)
+	return t, nil
+}
+
+func loadLocale(dir string, text *texttemplate.Template, html *htmltemplate.Template) (map[string]*Template, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	templates := make(map[string]*Template)
+	get := func(name string) *Template {
+		if templates[name] == nil {
+			templates[name] = &Template{}
+		}
+		return templates[name]
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		ext := filepath.Ext(entry.Name())
+		name := strings.TrimSuffix(entry.Name(), ext)
+		switch ext {
+		case ".txt":
+			clone, err := text.Clone()
+			if err != nil {
+				return nil, err
+			}
+			tmpl, err := clone.ParseFiles(path)
+			if err != nil {
+				return nil, err
+			}
+			get(name).Text = tmpl.Lookup(LayoutName)
+			if tmpl.Lookup(SubjectName) != nil {
+				var subject strings.Builder
+				if err = tmpl.ExecuteTemplate(&subject, SubjectName, nil); err != nil {
+					return nil, err
+				}
+				get(name).Subject = strings.TrimSpace(subject.String())
+			}
+		case ".html":
+			clone, err := html.Clone()
+			if err != nil {
+				return nil, err
+			}
+			tmpl, err := clone.ParseFiles(path)
+			if err != nil {
+				return nil, err
+			}
+			get(name).HTML = tmpl.Lookup(LayoutName)
+		}
+	}
+	for name, tmpl := range templates {
+		if tmpl.Subject == "" {
+			return nil, fmt.Errorf("%w: %s/%s", ErrMissingSubject, dir, name)
+		}
+	}
+	return templates, nil
+}
+
+func (t *Templates) candidates(preferred []string) []string {
+	var (
+		tags    []language.Tag
+		locales []string
+	)
+	for _, p := range preferred {
+		parsed, _, err := language.ParseAcceptLanguage(p)
+		if err != nil {
+			continue
+		}
+		for _, tag := range parsed {
+			tags = append(tags, tag)
+			for ; !tag.IsRoot(); tag = tag.Parent() {
+				locales = append(locales, tag.String())
+			}
+		}
+	}
+	_, i, _ := t.matcher.Match(tags...)
+	return append(locales, t.locales[i], t.locales[0])
+}
+
+func (t *Templates) Locale(preferred ...string) string {
+	for _, locale := range t.candidates(preferred) {
+		if _, ok := t.templates[locale]; ok {
+			return locale
+		}
+	}
+	return t.locales[0]
+}
+
+func (t *Templates) Has(name string) bool {
+	_, ok := t.templates[t.locales[0]][name]
+	return ok
+}
+
+func (t *Templates) Get(name string, preferred ...string) (*Template, error) {
+	for _, locale := range t.candidates(preferred) {
+		if tmpl, ok := t.templates[locale][name]; ok {
+			return tmpl, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+}
+
+func (t *Templates) Preview(w io.Writer, name, locale string, data any) error {
+	tmpl, err := t.Get(name, locale)
+	if err != nil {
+		return err
+	}
+	text, html, err := tmpl.Render(data)
+	if err != nil {
+		return err
+	}
+	return Write(w, &Message{
+		From:      mail.Address{Name: "Preview", Address: "preview@example.com"},
+		To:        mail.Address{Name: "Preview", Address: "preview@example.com"},
+		Subject:   tmpl.Subject,
+		Date:      time.Now(),
+		MessageID: "<preview@example.com>",
+		Text:      text,
+		HTML:      html,
+	})
+}
@@ -0,0 +1,142 @@
+package smtp
+
+import (
+	"bytes"
+	"errors"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type templateData struct {
+	Name   string
+	Email  string
+	Token  string
+	Link   string
+	Expiry time.Duration
+}
+
+func writeTemplates(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+var templateFiles = map[string]string{
+	"layout.txt":        `{{template "content" .}}`,
+	"layout.html":       `<div>{{template "content" .}}</div>`,
+	"en/welcome.txt":    `{{define "subject"}}Welcome{{end}}{{define "content"}}Hello {{.Name}}, {{.Token}} expires in {{minutes .Expiry}} minutes{{end}}`,
+	"en/welcome.html":   `{{define "content"}}<p>Hello {{.Name}}</p>{{end}}`,
+	"pl/welcome.txt":    `{{define "subject"}}Witaj{{end}}{{define "content"}}Cześć {{.Name}}{{end}}`,
+	"en/goodbye.txt":    `{{define "subject"}}Goodbye{{end}}{{define "content"}}Bye {{.Name}}{{end}}`,
+	"pl-PL/goodbye.txt": `{{define "subject"}}Do widzenia{{end}}{{define "content"}}Pa {{.Name}}{{end}}`,
+}
+
+func TestTemplates(t *testing.T) {
+	templates, err := LoadTemplates(&TemplatesConfig{Dir: writeTemplates(t, templateFiles), DefaultLocale: "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := templateData{Name: "<Bob>", Token: "abc", Expiry: 15 * time.Minute}
+	tests := []struct {
+		name      string
+		preferred []string
+		subject   string
+		text      string
+		html      string
+	}{
+		{"welcome", nil, "Welcome", "Hello <Bob>, abc expires in 15 minutes", "<div><p>Hello &lt;Bob&gt;</p></div>"},
+		{"welcome", []string{"pl"}, "Witaj", "Cześć <Bob>", ""},
+		{"welcome", []string{"", "pl-PL,pl;q=0.9,en;q=0.8"}, "Witaj", "Cześć <Bob>", ""},
+		{"welcome", []string{"de"}, "Welcome", "Hello <Bob>, abc expires in 15 minutes", "<div><p>Hello &lt;Bob&gt;</p></div>"},
+		{"goodbye", []string{"pl-PL"}, "Do widzenia", "Pa <Bob>", ""},
+		{"goodbye", []string{"pl"}, "Goodbye", "Bye <Bob>", ""},
+	}
+	for _, test := range tests {
+		tmpl, err := templates.Get(test.name, test.preferred...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tmpl.Subject != test.subject {
+			t.Fatalf("expected subject: %s, got: %s", test.subject, tmpl.Subject)
+		}
+		text, html, err := tmpl.Render(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text != test.text || html != test.html {
+			t.Fatalf("expected content: %q %q, got: %q %q", test.text, test.html, text, html)
+		}
+	}
+	if _, err = templates.Get("missing", "en"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Fatalf("expected error: %v, got: %v", ErrTemplateNotFound, err)
+	}
+	var buf bytes.Buffer
+	if err = templates.Preview(&buf, "welcome", "en", data); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := msg.Header.Get("Subject"); got != "Welcome" {
+		t.Fatalf("expected subject: %s, got: %s", "Welcome", got)
+	}
+}
+
+func TestLoadTemplates(t *testing.T) {
+	templates, err := LoadTemplates(&TemplatesConfig{Dir: filepath.Join("..", "templates"), DefaultLocale: "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := templates.Get("confirmation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := "https://app.example.com/confirm-email?token=abc"
+	for _, data := range []templateData{{Token: "abc"}, {Token: "abc", Link: link}} {
+		text, html, err := tmpl.Render(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(text, data.Token) || !strings.Contains(html, data.Token) {
+			t.Fatalf("expected token: %s in content", data.Token)
+		}
+		if hasLink := strings.Contains(html, `href="`+link+`"`); hasLink != (data.Link != "") {
+			t.Fatalf("expected link: %t, got: %t", data.Link != "", hasLink)
+		}
+	}
+	tests := []struct {
+		files  map[string]string
+		locale string
+		err    error
+	}{
+		{templateFiles, "de", ErrMissingLocale},
+		{templateFiles, "pl", ErrIncompleteLocale},
+		{map[string]string{
+			"layout.txt":     `{{template "content" .}}`,
+			"layout.html":    `{{template "content" .}}`,
+			"en/welcome.txt": `{{define "content"}}Hello{{end}}`,
+		}, "en", ErrMissingSubject},
+	}
+	for _, test := range tests {
+		_, err := LoadTemplates(&TemplatesConfig{Dir: writeTemplates(t, test.files), DefaultLocale: test.locale})
+		if !errors.Is(err, test.err) {
+			t.Fatalf("expected error: %v, got: %v", test.err, err)
+		}
+	}
+	if _, err := LoadTemplates(&TemplatesConfig{}); err != ErrMissingTemplateDir {
+		t.Fatalf("expected error: %v, got: %v", ErrMissingTemplateDir, err)
+	}
+}
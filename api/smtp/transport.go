@@ -0,0 +1,84 @@
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+
+	"github.com/emersion/go-sasl"
+	"github.com/emersion/go-smtp"
+)
+
+const (
+	TransportSMTP = "smtp"
+	TransportHTTP = "http"
+)
+
+type Transport interface {
+	Ping() error
+	Send(ctx context.Context, from, to string, message []byte) error
+	Close()
+}
+
+func NewTransport(cfg *Config) (Transport, error) {
+	switch cfg.Transport {
+	case TransportSMTP, "":
+		return NewSMTPTransport(cfg)
+	case TransportHTTP:
+		return NewHTTPTransport(&cfg.HTTP)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTransport, cfg.Transport)
+	}
+}
+
+func NewSMTPTransport(cfg *Config) (Transport, error) {
+	if cfg.Host == "" || cfg.Port == "" {
+		return nil, ErrMissingAddress
+	}
+	t := &smtpTransport{
+		addr:      net.JoinHostPort(cfg.Host, cfg.Port),
+		name:      cfg.Name,
+		tlsConfig: cfg.TLSConfig,
+	}
+	t.pool = newPool(&cfg.Pool, cfg.Name, sasl.NewPlainClient("", cfg.Username, cfg.Password), t.connect)
+	return t, nil
+}
+
+type smtpTransport struct {
+	addr      string
+	name      string
+	tlsConfig *tls.Config
+	pool      *pool
+}
+
+func (t *smtpTransport) connect() (*smtp.Client, error) {
+	if t.tlsConfig != nil {
+		return smtp.DialStartTLS(t.addr, t.tlsConfig)
+	} else {
+		return smtp.Dial(t.addr)
+	}
+}
+
+func (t *smtpTransport) Ping() error {
+	c, err := t.connect()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err = c.Hello(t.name); err != nil {
+		return err
+	}
+	if err = c.Noop(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (t *smtpTransport) Send(ctx context.Context, from, to string, message []byte) error {
+	return t.pool.send(ctx, from, to, message)
+}
+
+func (t *smtpTransport) Close() {
+	t.pool.close()
+}
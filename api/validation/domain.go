@@ -0,0 +1,220 @@
+package validation
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type DomainConfig struct {
+	Allowed        []string      `yaml:"allowed"`
+	Blocked        []string      `yaml:"blocked"`
+	AllowedFile    string        `yaml:"allowedFile"`
+	BlockedFile    string        `yaml:"blockedFile"`
+	ReloadInterval time.Duration `yaml:"reloadInterval"`
+	ErrorLog       *log.Logger
+}
+
+type DomainService interface {
+	Service[string]
+	Reload() error
+	Close()
+}
+
+func NewDomainService(cfg *DomainConfig) (DomainService, error) {
+	if cfg.ErrorLog == nil {
+		cfg.ErrorLog = log.Default()
+	}
+	s := &domainService{
+		cfg:  cfg,
+		done: make(chan struct{}),
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	if cfg.ReloadInterval > 0 && (cfg.AllowedFile != "" || cfg.BlockedFile != "") {
+		s.wg.Add(1)
+		go s.watch()
+	}
+	return s, nil
+}
+
+type domainSet struct {
+	exact    map[string]struct{}
+	wildcard map[string]struct{}
+}
+
+func newDomainSet(domains []string) *domainSet {
+	set := &domainSet{make(map[string]struct{}), make(map[string]struct{})}
+	for _, domain := range domains {
+		if suffix, ok := strings.CutPrefix(strings.TrimSpace(domain), "*."); ok {
+			set.wildcard[normalizeDomain(suffix)] = struct{}{}
+		} else {
+			set.exact[normalizeDomain(domain)] = struct{}{}
+		}
+	}
+	return set
+}
+
+func (set *domainSet) empty() bool {
+	return len(set.exact) == 0 && len(set.wildcard) == 0
+}
+
+func (set *domainSet) contains(domain string) bool {
+	if _, ok := set.exact[domain]; ok {
+		return true
+	}
+	i := strings.IndexByte(domain, '.')
+	return i >= 0 && set.covers(domain[i+1:])
+}
+
+func (set *domainSet) covers(suffix string) bool {
+	for {
+		if _, ok := set.wildcard[suffix]; ok {
+			return true
+		}
+		i := strings.IndexByte(suffix, '.')
+		if i < 0 {
+			return false
+		}
+		suffix = suffix[i+1:]
+	}
+}
+
+func readDomainFile(name string) ([]string, time.Time, error) {
+	if name == "" {
+		return nil, time.Time{}, nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var domains []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			domains = append(domains, line)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, time.Time{}, err
+	}
+	return domains, info.ModTime(), nil
+}
+
+type domainService struct {
+	cfg        *DomainConfig
+	allowed    *domainSet
+	blocked    *domainSet
+	modifiedAt [2]time.Time
+	done       chan struct{}
+	wg         sync.WaitGroup
+	mutex      sync.RWMutex
+}
+
+func (s *domainService) Reload() error {
+	allowedFile, allowedAt, err := readDomainFile(s.cfg.AllowedFile)
+	if err != nil {
+		return err
+	}
+	blockedFile, blockedAt, err := readDomainFile(s.cfg.BlockedFile)
+	if err != nil {
+		return err
+	}
+	allowed := newDomainSet(append(allowedFile, s.cfg.Allowed...))
+	blocked := newDomainSet(append(blockedFile, s.cfg.Blocked...))
+	for domain := range allowed.exact {
+		if blocked.contains(domain) {
+			return fmt.Errorf("%w: %s", ErrConflictingDomain, domain)
+		}
+	}
+	for suffix := range allowed.wildcard {
+		if blocked.covers(suffix) {
+			return fmt.Errorf("%w: *.%s", ErrConflictingDomain, suffix)
+		}
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.allowed = allowed
+	s.blocked = blocked
+	s.modifiedAt = [2]time.Time{allowedAt, blockedAt}
+	return nil
+}
+
+type fileState struct {
+	modifiedAt time.Time
+	err        string
+}
+
+func (s *domainService) modified(observed *[2]fileState) bool {
+	changed := false
+	for i, name := range []string{s.cfg.AllowedFile, s.cfg.BlockedFile} {
+		if name == "" {
+			continue
+		}
+		var state fileState
+		if info, err := os.Stat(name); err != nil {
+			state.err = err.Error()
+		} else {
+			state.modifiedAt = info.ModTime()
+		}
+		if state != observed[i] {
+			observed[i] = state
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (s *domainService) watch() {
+	defer s.wg.Done()
+	var observed [2]fileState
+	s.mutex.RLock()
+	for i, modifiedAt := range s.modifiedAt {
+		observed[i].modifiedAt = modifiedAt
+	}
+	s.mutex.RUnlock()
+	ticker := time.NewTicker(s.cfg.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if !s.modified(&observed) {
+				continue
+			}
+			if err := s.Reload(); err != nil {
+				s.cfg.ErrorLog.Print(err)
+			}
+		}
+	}
+}
+
+func (s *domainService) Check(email string) []Violation {
+	domain := normalizeDomain(email[strings.LastIndexByte(email, '@')+1:])
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.blocked.contains(domain) {
+		return []Violation{{Rule: RuleBlockedDomain}}
+	}
+	if !s.allowed.empty() && !s.allowed.contains(domain) {
+		return []Violation{{Rule: RuleDomainNotAllowed}}
+	}
+	return nil
+}
+
+func (s *domainService) Close() {
+	close(s.done)
+	s.wg.Wait()
+}
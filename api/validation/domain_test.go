@@ -0,0 +1,150 @@
+package validation
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestDomainService(t *testing.T) {
+	svc, err := NewDomainService(&DomainConfig{
+		Blocked: []string{"Spam.com", "*.mailinator.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.Close()
+	tests := []struct {
+		email string
+		rules []string
+	}{
+		{"bob@example.com", []string{}},
+		{"bob@spam.COM", []string{RuleBlockedDomain}},
+		{"bob@sub.spam.com", []string{}},
+		{"bob@mailinator.com", []string{}},
+		{"bob@x.mailinator.com", []string{RuleBlockedDomain}},
+		{"bob@a.b.mailinator.com", []string{RuleBlockedDomain}},
+		{"bob@notmailinator.com", []string{}},
+	}
+	for _, test := range tests {
+		if got := rules(svc.Check(test.email)); !slices.Equal(got, test.rules) {
+			t.Fatalf("expected rules: %v for %q, got: %v", test.rules, test.email, got)
+		}
+	}
+}
+
+func TestDomainServiceAllowed(t *testing.T) {
+	svc, err := NewDomainService(&DomainConfig{
+		Allowed: []string{"example.com", "*.corp.example.com"},
+		Blocked: []string{"old.corp.example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.Close()
+	tests := []struct {
+		email string
+		rules []string
+	}{
+		{"bob@example.com", []string{}},
+		{"bob@eu.corp.example.com", []string{}},
+		{"bob@corp.example.com", []string{RuleDomainNotAllowed}},
+		{"bob@old.corp.example.com", []string{RuleBlockedDomain}},
+		{"bob@other.com", []string{RuleDomainNotAllowed}},
+	}
+	for _, test := range tests {
+		if got := rules(svc.Check(test.email)); !slices.Equal(got, test.rules) {
+			t.Fatalf("expected rules: %v for %q, got: %v", test.rules, test.email, got)
+		}
+	}
+}
+
+func TestDomainServiceReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "blocked.txt")
+	if err := os.WriteFile(file, []byte("# disposable\nspam.com\n\n*.trash.io # wildcard\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	svc, err := NewDomainService(&DomainConfig{BlockedFile: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.Close()
+	for _, email := range []string{"bob@spam.com", "bob@x.trash.io"} {
+		if got := rules(svc.Check(email)); !slices.Equal(got, []string{RuleBlockedDomain}) {
+			t.Fatalf("expected rules: %v for %q, got: %v", []string{RuleBlockedDomain}, email, got)
+		}
+	}
+	if err = os.WriteFile(file, []byte("trash.io\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err = svc.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := rules(svc.Check("bob@spam.com")); len(got) != 0 {
+		t.Fatalf("expected no rules, got: %v", got)
+	}
+	if got := rules(svc.Check("bob@trash.io")); !slices.Equal(got, []string{RuleBlockedDomain}) {
+		t.Fatalf("expected rules: %v, got: %v", []string{RuleBlockedDomain}, got)
+	}
+}
+
+func TestDomainServiceErrors(t *testing.T) {
+	for _, test := range []struct {
+		allowed []string
+		blocked []string
+	}{
+		{[]string{"a.com"}, []string{"A.com"}},
+		{[]string{"x.a.com"}, []string{"*.a.com"}},
+		{[]string{"*.a.com"}, []string{"*.a.com"}},
+		{[]string{"*.x.a.com"}, []string{"*.a.com"}},
+	} {
+		if _, err := NewDomainService(&DomainConfig{
+			Allowed: test.allowed,
+			Blocked: test.blocked,
+		}); !errors.Is(err, ErrConflictingDomain) {
+			t.Fatalf("expected error: %v for allowed: %v and blocked: %v, got: %v", ErrConflictingDomain, test.allowed, test.blocked, err)
+		}
+	}
+	if _, err := NewDomainService(&DomainConfig{
+		BlockedFile: filepath.Join(t.TempDir(), "missing.txt"),
+	}); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected error: %v, got: %v", os.ErrNotExist, err)
+	}
+}
+
+func TestDomainServiceModified(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "blocked.txt")
+	if err := os.WriteFile(file, []byte("spam.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	svc, err := NewDomainService(&DomainConfig{BlockedFile: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.Close()
+	s := svc.(*domainService)
+	observed := [2]fileState{{}, {modifiedAt: s.modifiedAt[1]}}
+	tests := []struct {
+		change   func() error
+		expected bool
+	}{
+		{nil, false},
+		{func() error { return os.Remove(file) }, true},
+		{nil, false},
+		{nil, false},
+		{func() error { return os.WriteFile(file, []byte("trash.io\n"), 0o644) }, true},
+		{nil, false},
+	}
+	for i, test := range tests {
+		if test.change != nil {
+			if err = test.change(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if modified := s.modified(&observed); modified != test.expected {
+			t.Fatalf("expected modified: %t at step %d, got: %t", test.expected, i, modified)
+		}
+	}
+}
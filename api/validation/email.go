@@ -0,0 +1,107 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var DefaultEmailConfig = &EmailConfig{
+	Pattern: `^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`,
+}
+
+type Email struct {
+	Address   string
+	Canonical string
+}
+
+type EmailConfig struct {
+	Pattern       string              `yaml:"pattern"`
+	Normalization NormalizationConfig `yaml:"normalization"`
+}
+
+type NormalizationConfig struct {
+	Providers []ProviderConfig `yaml:"providers"`
+}
+
+type ProviderConfig struct {
+	Domains         []string `yaml:"domains"`
+	CanonicalDomain string   `yaml:"canonicalDomain"`
+	IgnoreDots      bool     `yaml:"ignoreDots"`
+	TagSeparator    string   `yaml:"tagSeparator"`
+}
+
+type EmailService interface {
+	Service[string]
+	Normalize(string) Email
+}
+
+func NewEmailService(cfg *EmailConfig) (EmailService, error) {
+	pattern, err := regexp.Compile(cfg.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPattern, err)
+	}
+	providers := make(map[string]*ProviderConfig)
+	for i := range cfg.Normalization.Providers {
+		p := &cfg.Normalization.Providers[i]
+		for _, domain := range normalizeDomains(p.Domains) {
+			providers[domain] = p
+		}
+	}
+	return &emailService{pattern, providers}, nil
+}
+
+func normalizeDomain(domain string) string {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	if ascii, err := idna.Lookup.ToASCII(domain); err == nil {
+		return ascii
+	}
+	return domain
+}
+
+func normalizeDomains(domains []string) []string {
+	normalized := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		normalized = append(normalized, normalizeDomain(domain))
+	}
+	return normalized
+}
+
+type emailService struct {
+	pattern   *regexp.Regexp
+	providers map[string]*ProviderConfig
+}
+
+func (s *emailService) Normalize(email string) Email {
+	email = strings.TrimSpace(email)
+	i := strings.LastIndexByte(email, '@')
+	if i < 0 {
+		return Email{email, email}
+	}
+	local, domain := email[:i], normalizeDomain(email[i+1:])
+	canonicalLocal, canonicalDomain := strings.ToLower(local), domain
+	if p, ok := s.providers[domain]; ok {
+		if p.TagSeparator != "" {
+			canonicalLocal, _, _ = strings.Cut(canonicalLocal, p.TagSeparator)
+		}
+		if p.IgnoreDots {
+			canonicalLocal = strings.ReplaceAll(canonicalLocal, ".", "")
+		}
+		if p.CanonicalDomain != "" {
+			canonicalDomain = normalizeDomain(p.CanonicalDomain)
+		}
+	}
+	return Email{
+		Address:   local + "@" + domain,
+		Canonical: canonicalLocal + "@" + canonicalDomain,
+	}
+}
+
+func (s *emailService) Check(email string) []Violation {
+	if !s.pattern.MatchString(email) {
+		return []Violation{{Rule: RuleBadFormat}}
+	}
+	return nil
+}
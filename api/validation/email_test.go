@@ -0,0 +1,70 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEmailService(t *testing.T) {
+	svc, err := NewEmailService(DefaultEmailConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		email string
+		valid bool
+	}{
+		{"a@b.cd", true},
+		{"john@example.com", true},
+		{"a@b", false},
+		{"gmail.com", false},
+		{"bob@", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if valid := len(svc.Check(test.email)) == 0; valid != test.valid {
+			t.Fatalf("expected %t for %q", test.valid, test.email)
+		}
+	}
+}
+
+func TestEmailServiceNormalize(t *testing.T) {
+	svc, err := NewEmailService(&EmailConfig{
+		Pattern: DefaultEmailConfig.Pattern,
+		Normalization: NormalizationConfig{
+			Providers: []ProviderConfig{{
+				Domains:         []string{"gmail.com", "googlemail.com"},
+				CanonicalDomain: "gmail.com",
+				IgnoreDots:      true,
+				TagSeparator:    "+",
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		email    string
+		expected Email
+	}{
+		{" Bob@Example.COM ", Email{"Bob@example.com", "bob@example.com"}},
+		{"bob+news@example.com", Email{"bob+news@example.com", "bob+news@example.com"}},
+		{"John.Doe+Spam@GoogleMail.com", Email{"John.Doe+Spam@googlemail.com", "johndoe@gmail.com"}},
+		{"jan@żółw.pl", Email{"jan@xn--w-uga1v8h.pl", "jan@xn--w-uga1v8h.pl"}},
+		{"bob", Email{"bob", "bob"}},
+	}
+	for _, test := range tests {
+		if got := svc.Normalize(test.email); got != test.expected {
+			t.Fatalf("expected email: %v for %q, got: %v", test.expected, test.email, got)
+		}
+	}
+	if violations := svc.Check(svc.Normalize("jan@żółw.pl").Address); len(violations) != 0 {
+		t.Fatalf("expected no violations, got: %v", violations)
+	}
+}
+
+func TestEmailServiceErrors(t *testing.T) {
+	if _, err := NewEmailService(&EmailConfig{Pattern: "("}); !errors.Is(err, ErrInvalidPattern) {
+		t.Fatalf("expected error: %v, got: %v", ErrInvalidPattern, err)
+	}
+}
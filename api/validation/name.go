@@ -0,0 +1,122 @@
+package validation
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	scripts = func() []*unicode.RangeTable {
+		tables := make([]*unicode.RangeTable, 0, len(unicode.Scripts))
+		for name, table := range unicode.Scripts {
+			if name != "Common" && name != "Inherited" {
+				tables = append(tables, table)
+			}
+		}
+		return tables
+	}()
+	scriptCombinations = [][]*unicode.RangeTable{
+		{unicode.Latin, unicode.Han, unicode.Hiragana, unicode.Katakana},
+		{unicode.Latin, unicode.Han, unicode.Hangul},
+		{unicode.Latin, unicode.Han, unicode.Bopomofo},
+	}
+)
+
+type NameConfig struct {
+	Min                int  `yaml:"min"`
+	Max                int  `yaml:"max"`
+	RejectMixedScripts bool `yaml:"rejectMixedScripts"`
+}
+
+type NameService interface {
+	Service[string]
+	Normalize(string) string
+}
+
+func NewNameService(cfg *NameConfig) (NameService, error) {
+	if err := (Range{cfg.Min, cfg.Max}).Validate(); err != nil {
+		return nil, err
+	}
+	return &nameService{cfg}, nil
+}
+
+type nameService struct {
+	cfg *NameConfig
+}
+
+func (s *nameService) Normalize(name string) string {
+	return norm.NFC.String(strings.TrimSpace(name))
+}
+
+func isForbidden(r rune) bool {
+	switch {
+	case r == '\u200c' || r == '\u200d':
+		return false
+	case unicode.In(r, unicode.Cc, unicode.Cf, unicode.Co, unicode.Cs, unicode.Noncharacter_Code_Point):
+		return true
+	}
+	return r == unicode.ReplacementChar
+}
+
+func scriptOf(r rune) *unicode.RangeTable {
+	for _, table := range scripts {
+		if unicode.Is(table, r) {
+			return table
+		}
+	}
+	return nil
+}
+
+func isMixed(used []*unicode.RangeTable) bool {
+	if len(used) < 2 {
+		return false
+	}
+	for _, combination := range scriptCombinations {
+		covered := true
+		for _, table := range used {
+			if !slices.Contains(combination, table) {
+				covered = false
+				break
+			}
+		}
+		if covered {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *nameService) Check(name string) []Violation {
+	var (
+		violations []Violation
+		used       []*unicode.RangeTable
+	)
+	for _, r := range name {
+		if isForbidden(r) {
+			violations = append(violations, Violation{Rule: RuleForbiddenCharacter})
+			break
+		}
+	}
+	if s.cfg.RejectMixedScripts {
+		for _, r := range name {
+			if table := scriptOf(r); table != nil && !slices.Contains(used, table) {
+				used = append(used, table)
+			}
+		}
+		if isMixed(used) {
+			violations = append(violations, Violation{Rule: RuleMixedScripts})
+		}
+	}
+	length := uniseg.GraphemeClusterCount(name)
+	if length < s.cfg.Min {
+		violations = append(violations, Violation{RuleMinLength, map[string]int{"min": s.cfg.Min}})
+	}
+	if length > s.cfg.Max {
+		violations = append(violations, Violation{RuleTooLong, map[string]int{"max": s.cfg.Max}})
+	}
+	return violations
+}
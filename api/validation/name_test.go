@@ -0,0 +1,57 @@
+package validation
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNameService(t *testing.T) {
+	svc, err := NewNameService(&NameConfig{Min: 3, Max: 10, RejectMixedScripts: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		rules []string
+	}{
+		{"John", []string{}},
+		{"Łukasz Żył", []string{}},
+		{"Żółć Łąka", []string{}},
+		{"山田太郎", []string{}},
+		{"やまだ Taro", []string{}},
+		{"김민준", []string{}},
+		{"\U0001f468\u200d\U0001f469\u200d\U0001f467", []string{RuleMinLength}},
+		{"Jo", []string{RuleMinLength}},
+		{"Bartholomew Jr", []string{RuleTooLong}},
+		{"Jo\u200bhn", []string{RuleForbiddenCharacter}},
+		{"John\u202egnp.exe", []string{RuleForbiddenCharacter, RuleTooLong}},
+		{"Jo\x00hn", []string{RuleForbiddenCharacter}},
+		{"P\u0430ypal", []string{RuleMixedScripts}},
+		{"Ivan Иван", []string{RuleMixedScripts}},
+	}
+	for _, test := range tests {
+		if got := rules(svc.Check(test.name)); !slices.Equal(got, test.rules) {
+			t.Fatalf("expected rules: %v for %q, got: %v", test.rules, test.name, got)
+		}
+	}
+	svc, err = NewNameService(&NameConfig{Min: 3, Max: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rules(svc.Check("Ivan Иван")); len(got) != 0 {
+		t.Fatalf("expected no rules, got: %v", got)
+	}
+}
+
+func TestNameServiceNormalize(t *testing.T) {
+	svc, err := NewNameService(&NameConfig{Min: 1, Max: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := svc.Normalize("  Zoe\u0301 "); got != "Zo\u00e9" {
+		t.Fatalf("expected name: %q, got: %q", "Zo\u00e9", got)
+	}
+	if _, err = NewNameService(&NameConfig{Min: 10, Max: 1}); err != ErrInvalidRange {
+		t.Fatalf("expected error: %v, got: %v", ErrInvalidRange, err)
+	}
+}
@@ -1,25 +1,49 @@
 package validation
 
 import (
-	"regexp"
+	"errors"
 	"unicode"
 	"unicode/utf8"
 )
 
+const (
+	RuleMinLength          = "min_length"
+	RuleTooLong            = "too_long"
+	RuleMissingUpper       = "missing_upper"
+	RuleMissingLower       = "missing_lower"
+	RuleMissingNumber      = "missing_number"
+	RuleMissingSpecial     = "missing_special"
+	RuleBadFormat          = "bad_format"
+	RuleBlockedDomain      = "blocked_domain"
+	RuleDomainNotAllowed   = "domain_not_allowed"
+	RuleForbiddenCharacter = "forbidden_character"
+	RuleMixedScripts       = "mixed_scripts"
+)
+
 var (
-	DefaultPasswordConfig = &PasswordConfig{
-		Upper:     1,
-		Lower:     1,
-		Number:    1,
-		Special:   1,
-		MinLength: 12,
-		MaxLength: 64,
-	}
-	DefaultEmailPattern = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+	ErrNegativeBound         = errors.New("validation: bound must not be negative")
+	ErrInvalidRange          = errors.New("validation: minimum must not exceed maximum")
+	ErrUnsatisfiablePassword = errors.New("validation: required characters exceed maximum length")
+	ErrInvalidPattern        = errors.New("validation: invalid pattern")
+	ErrConflictingDomain     = errors.New("validation: domain is both allowed and blocked")
 )
 
+var DefaultPasswordConfig = &PasswordConfig{
+	Upper:     1,
+	Lower:     1,
+	Number:    1,
+	Special:   1,
+	MinLength: 12,
+	MaxLength: 64,
+}
+
+type Violation struct {
+	Rule   string         `json:"rule"`
+	Params map[string]int `json:"params,omitempty"`
+}
+
 type Service[T any] interface {
-	Check(T) bool
+	Check(T) []Violation
 }
 
 type PasswordConfig struct {
@@ -31,22 +55,41 @@ type PasswordConfig struct {
 	MaxLength int `yaml:"maxLength"`
 }
 
-func NewPasswordService(cfg *PasswordConfig) Service[[]byte] {
-	return &passwordService{cfg}
+func (cfg *PasswordConfig) Validate() error {
+	for _, v := range []int{cfg.Upper, cfg.Lower, cfg.Number, cfg.Special, cfg.MinLength, cfg.MaxLength} {
+		if v < 0 {
+			return ErrNegativeBound
+		}
+	}
+	if cfg.MinLength > cfg.MaxLength {
+		return ErrInvalidRange
+	}
+	if cfg.Upper+cfg.Lower+cfg.Number+cfg.Special > cfg.MaxLength {
+		return ErrUnsatisfiablePassword
+	}
+	return nil
+}
+
+func NewPasswordService(cfg *PasswordConfig) (Service[[]byte], error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &passwordService{cfg}, nil
 }
 
 type passwordService struct {
 	cfg *PasswordConfig
 }
 
-func (s *passwordService) Check(password []byte) bool {
+func (s *passwordService) Check(password []byte) []Violation {
 	var (
-		r       rune
-		size    int
-		upper   int
-		lower   int
-		number  int
-		special int
+		r          rune
+		size       int
+		upper      int
+		lower      int
+		number     int
+		special    int
+		violations []Violation
 	)
 	for i := 0; i < len(password); {
 		r, size = utf8.DecodeRune(password[i:])
@@ -62,24 +105,27 @@ func (s *passwordService) Check(password []byte) bool {
 		}
 		i += size
 	}
-	return upper >= s.cfg.Upper &&
-		lower >= s.cfg.Lower &&
-		number >= s.cfg.Number &&
-		special >= s.cfg.Special &&
-		len(password) >= s.cfg.MinLength &&
-		len(password) <= s.cfg.MaxLength
-}
-
-func NewEmailService(pattern *regexp.Regexp) Service[string] {
-	return &emailService{pattern}
-}
-
-type emailService struct {
-	pattern *regexp.Regexp
-}
-
-func (s *emailService) Check(email string) bool {
-	return s.pattern.MatchString(email)
+	if len(password) < s.cfg.MinLength {
+		violations = append(violations, Violation{RuleMinLength, map[string]int{"min": s.cfg.MinLength}})
+	}
+	if len(password) > s.cfg.MaxLength {
+		violations = append(violations, Violation{RuleTooLong, map[string]int{"max": s.cfg.MaxLength}})
+	}
+	for _, class := range []struct {
+		rule     string
+		count    int
+		required int
+	}{
+		{RuleMissingUpper, upper, s.cfg.Upper},
+		{RuleMissingLower, lower, s.cfg.Lower},
+		{RuleMissingNumber, number, s.cfg.Number},
+		{RuleMissingSpecial, special, s.cfg.Special},
+	} {
+		if class.count < class.required {
+			violations = append(violations, Violation{class.rule, map[string]int{"min": class.required}})
+		}
+	}
+	return violations
 }
 
 type Range struct {
@@ -87,15 +133,12 @@ type Range struct {
 	Max int `yaml:"max"`
 }
 
-func NewMinMaxService(r Range) Service[string] {
-	return &minMaxService{r.Min, r.Max}
-}
-
-type minMaxService struct {
-	min int
-	max int
-}
-
-func (s *minMaxService) Check(value string) bool {
-	return len(value) >= s.min && len(value) <= s.max
+func (r Range) Validate() error {
+	if r.Min < 0 || r.Max < 0 {
+		return ErrNegativeBound
+	}
+	if r.Min > r.Max {
+		return ErrInvalidRange
+	}
+	return nil
 }
@@ -1,64 +1,60 @@
 package validation
 
 import (
+	"slices"
 	"testing"
 )
 
-func TestPasswordService(t *testing.T) {
-	svc := NewPasswordService(DefaultPasswordConfig)
-	tests := []struct {
-		password []byte
-		valid    bool
-	}{
-		{[]byte("Pa$$word1234"), true},
-		{[]byte("Cz3sław!!!:D"), true},
-		{[]byte("Θct0pu$+!2217"), true},
-		{[]byte("βetter3...:)"), false},
-		{[]byte("Titanic1912"), false},
-		{[]byte(""), false},
-	}
-	for _, test := range tests {
-		if svc.Check(test.password) != test.valid {
-			t.Fatalf("expected %t for %q", test.valid, test.password)
-		}
+func rules(violations []Violation) []string {
+	r := make([]string, 0, len(violations))
+	for _, v := range violations {
+		r = append(r, v.Rule)
 	}
+	return r
 }
 
-func TestEmailService(t *testing.T) {
-	svc := NewEmailService(DefaultEmailPattern)
+func TestPasswordService(t *testing.T) {
+	svc, err := NewPasswordService(DefaultPasswordConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
 	tests := []struct {
-		email string
-		valid bool
+		password []byte
+		rules    []string
 	}{
-		{"a@b.cd", true},
-		{"john@example.com", true},
-		{"a@b", false},
-		{"gmail.com", false},
-		{"bob@", false},
-		{"", false},
+		{[]byte("Pa$$word1234"), []string{}},
+		{[]byte("Cz3sław!!!:D"), []string{}},
+		{[]byte("Θct0pu$+!2217"), []string{}},
+		{[]byte("βetter3...:)"), []string{RuleMissingUpper}},
+		{[]byte("Titanic1912"), []string{RuleMinLength, RuleMissingSpecial}},
+		{[]byte("Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!"), []string{RuleTooLong}},
+		{[]byte(""), []string{RuleMinLength, RuleMissingUpper, RuleMissingLower, RuleMissingNumber, RuleMissingSpecial}},
 	}
 	for _, test := range tests {
-		if svc.Check(test.email) != test.valid {
-			t.Fatalf("expected %t for %q", test.valid, test.email)
+		if got := rules(svc.Check(test.password)); !slices.Equal(got, test.rules) {
+			t.Fatalf("expected rules: %v for %q, got: %v", test.rules, test.password, got)
 		}
 	}
+	violations := svc.Check([]byte("Titanic1912"))
+	if violations[0].Params["min"] != DefaultPasswordConfig.MinLength {
+		t.Fatalf("expected min param: %d, got: %d", DefaultPasswordConfig.MinLength, violations[0].Params["min"])
+	}
 }
 
-func TestMinMaxService(t *testing.T) {
-	svc := NewMinMaxService(Range{1, 10})
+func TestConfigErrors(t *testing.T) {
 	tests := []struct {
-		value string
-		valid bool
+		err      error
+		expected error
 	}{
-		{"aaa", true},
-		{"bbbbbbbbbb", true},
-		{"c", true},
-		{"", false},
-		{"dddddddddddd", false},
+		{(&PasswordConfig{MinLength: 20, MaxLength: 10}).Validate(), ErrInvalidRange},
+		{(&PasswordConfig{Upper: -1, MaxLength: 10}).Validate(), ErrNegativeBound},
+		{(&PasswordConfig{Upper: 4, Lower: 4, Number: 4, MaxLength: 10}).Validate(), ErrUnsatisfiablePassword},
+		{Range{5, 3}.Validate(), ErrInvalidRange},
+		{Range{-1, 3}.Validate(), ErrNegativeBound},
 	}
 	for _, test := range tests {
-		if svc.Check(test.value) != test.valid {
-			t.Fatalf("expected %t for %q", test.valid, test.value)
+		if test.err != test.expected {
+			t.Fatalf("expected error: %v, got: %v", test.expected, test.err)
 		}
 	}
 }